@@ -0,0 +1,212 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"EMInit/pkg/utils"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const bulkDownloadWorkers = 5 // 批量下载的并发数
+
+// bulkResult 批量下载中单个SN的结果
+type bulkResult struct {
+	sn      string
+	status  string
+	message string
+}
+
+// BulkDownloader 批量下载设备初始配置
+type BulkDownloader struct {
+	tool    *FirmwareFlashTool
+	running int32
+
+	snEntry     *widget.Entry
+	table       *widget.Table
+	startButton *widget.Button
+	retryButton *widget.Button
+
+	results []*bulkResult
+	lock    sync.Mutex
+}
+
+func NewBulkDownloader(t *FirmwareFlashTool) *BulkDownloader {
+	b := &BulkDownloader{
+		tool:    t,
+		snEntry: widget.NewMultiLineEntry(),
+	}
+	b.snEntry.SetPlaceHolder("粘贴SN列表，每行一个或用逗号分隔")
+	b.snEntry.SetMinRowsVisible(5)
+	return b
+}
+
+// Content 批量下载标签页内容
+func (b *BulkDownloader) Content() fyne.CanvasObject {
+	headers := []string{"SN", "状态", "信息"}
+	b.table = widget.NewTable(
+		func() (int, int) {
+			b.lock.Lock()
+			defer b.lock.Unlock()
+			return len(b.results), len(headers)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.TableCellID, cell fyne.CanvasObject) {
+			b.lock.Lock()
+			defer b.lock.Unlock()
+			if id.Row >= len(b.results) {
+				return
+			}
+			r := b.results[id.Row]
+			cell.(*widget.Label).SetText([]string{r.sn, r.status, r.message}[id.Col])
+		},
+	)
+	b.table.ShowHeaderRow = true
+	b.table.CreateHeader = func() fyne.CanvasObject {
+		return widget.NewLabel("")
+	}
+	b.table.UpdateHeader = func(id widget.TableCellID, cell fyne.CanvasObject) {
+		cell.(*widget.Label).SetText(headers[id.Col])
+	}
+	b.table.SetColumnWidth(0, 180)
+	b.table.SetColumnWidth(1, 90)
+	b.table.SetColumnWidth(2, 300)
+
+	b.startButton = widget.NewButton("开始下载", func() {
+		sns := version.ParseSNList(b.snEntry.Text)
+		if len(sns) == 0 {
+			dialog.ShowInformation("错误", "请输入设备SN", b.tool.window)
+			return
+		}
+		b.start(sns)
+	})
+
+	b.retryButton = widget.NewButton("重试失败", func() {
+		var sns []string
+		b.lock.Lock()
+		for _, r := range b.results {
+			if r.status != version.DownloadOK {
+				sns = append(sns, r.sn)
+			}
+		}
+		b.lock.Unlock()
+
+		if len(sns) == 0 {
+			dialog.ShowInformation("提示", "没有下载失败的SN", b.tool.window)
+			return
+		}
+		b.start(sns)
+	})
+
+	top := container.NewVBox(
+		container.NewVBox(widget.NewLabel("选择版本:"), b.tool.versionSelect),
+		widget.NewLabel("设备SN列表:"),
+		b.snEntry,
+		container.NewGridWithColumns(3,
+			widget.NewButton("导入文件", b.importFile),
+			b.startButton,
+			b.retryButton,
+		),
+	)
+
+	return container.NewBorder(top, nil, nil, nil, b.table)
+}
+
+// importFile 从文本文件导入SN列表
+func (b *BulkDownloader) importFile() {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil || reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			dialog.ShowError(err, b.tool.window)
+			return
+		}
+
+		sns := version.ParseSNList(b.snEntry.Text + "\n" + string(data))
+		text := ""
+		for _, sn := range sns {
+			text += sn + "\n"
+		}
+		b.snEntry.SetText(text)
+		b.tool.AppendOutput(fmt.Sprintf("已导入SN列表: %s, 共 %d 个", reader.URI().Name(), len(sns)))
+	}, b.tool.window)
+}
+
+// start 并发下载指定SN的配置，已有结果的SN会重新下载
+func (b *BulkDownloader) start(sns []string) {
+	if !atomic.CompareAndSwapInt32(&b.running, 0, 1) {
+		dialog.ShowInformation("提示", "正在批量下载，请稍候", b.tool.window)
+		return
+	}
+
+	// 保留已有结果，只重置本次下载的SN
+	b.lock.Lock()
+	index := make(map[string]*bulkResult)
+	for _, r := range b.results {
+		index[r.sn] = r
+	}
+	var results []*bulkResult
+	for _, sn := range sns {
+		r, ok := index[sn]
+		if !ok {
+			r = &bulkResult{sn: sn}
+		}
+		r.status, r.message = version.DownloadPending, ""
+		index[sn] = r
+		results = append(results, r)
+	}
+	for _, r := range b.results {
+		if !utils.ContainsString(sns, r.sn) {
+			results = append(results, r)
+		}
+	}
+	b.results = results
+	b.lock.Unlock()
+	b.table.Refresh()
+
+	b.startButton.Disable()
+	b.retryButton.Disable()
+	b.tool.AppendOutput(fmt.Sprintf("开始批量下载初始配置，共 %d 个SN", len(sns)))
+
+	v := b.tool.version
+	go func() {
+		defer func() {
+			b.startButton.Enable()
+			b.retryButton.Enable()
+			atomic.StoreInt32(&b.running, 0)
+		}()
+
+		version.BulkDownloadConfig(v, sns, bulkDownloadWorkers, func(sn, status string, err error) {
+			b.lock.Lock()
+			r := index[sn]
+			r.status = status
+			if err != nil {
+				r.message = err.Error()
+			}
+			b.lock.Unlock()
+			b.table.Refresh()
+		})
+
+		counts := make(map[string]int)
+		b.lock.Lock()
+		for _, sn := range sns {
+			counts[index[sn].status]++
+		}
+		b.lock.Unlock()
+
+		b.tool.AppendOutput(fmt.Sprintf("批量下载完成: 成功 %d 个, 未注册 %d 个, 解密失败 %d 个, HTTP错误 %d 个, 校验失败 %d 个, 其他失败 %d 个",
+			counts[version.DownloadOK], counts[version.DownloadNotRegistered], counts[version.DownloadDecryptFailed],
+			counts[version.DownloadHTTPError], counts[version.DownloadChecksumError], counts[version.DownloadFailed]))
+	}()
+}
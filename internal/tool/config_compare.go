@@ -0,0 +1,123 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// configCompareBox 配置对比操作区: 对比设备配置与ERP配置，推送ERP配置到设备或保存设备配置到主机
+func (t *FirmwareFlashTool) configCompareBox() fyne.CanvasObject {
+	var (
+		result    *version.ConfigCompare
+		resultSN  string
+		resultVer string
+	)
+
+	fromErpCheck := widget.NewCheck("从ERP平台重新获取(否则使用已下载的配置)", nil)
+	fromErpCheck.SetChecked(true)
+
+	compareButton := widget.NewButton("对比配置", nil)
+	compareButton.OnTapped = func() {
+		sn := t.snEntry.Text
+		if sn == "" {
+			dialog.ShowInformation("错误", "请输入设备SN", t.window)
+			return
+		}
+
+		compareButton.Disable()
+		go func() {
+			defer compareButton.Enable()
+
+			t.AppendOutput(fmt.Sprintf("开始对比设备 %s 的配置...", sn))
+			cmp, err := t.version.CompareConfig(sn, fromErpCheck.Checked)
+			if err != nil {
+				t.AppendOutput("对比配置失败: " + err.Error())
+				return
+			}
+			result, resultSN, resultVer = cmp, sn, t.versionSelect.Selected
+
+			if len(cmp.Diffs) == 0 {
+				t.AppendOutput("设备上的配置与ERP配置一致")
+				return
+			}
+
+			t.AppendOutput(fmt.Sprintf("设备上的配置与ERP配置共有 %d 处不同:", len(cmp.Diffs)))
+			section := ""
+			for _, diff := range cmp.Diffs {
+				if diff.Section != section {
+					section = diff.Section
+					t.AppendOutput(fmt.Sprintf("[%s]", section))
+				}
+				t.AppendOutput("  " + diff.String())
+			}
+		}()
+	}
+
+	// 推送和保存只能使用最近一次对比的结果
+	lastResult := func() bool {
+		if result == nil || resultSN != t.snEntry.Text || resultVer != t.versionSelect.Selected {
+			dialog.ShowInformation("提示", "请先对比当前设备的配置", t.window)
+			return false
+		}
+		return true
+	}
+
+	pushButton := widget.NewButton("推送ERP配置到设备", func() {
+		if !lastResult() {
+			return
+		}
+
+		sn, data := resultSN, result.Erp
+		dialog.ShowCustomConfirm("确认推送", "是", "否", widget.NewLabel(fmt.Sprintf("确定将ERP配置推送到设备 %s 并重启服务吗？", sn)), func(b bool) {
+			if !b {
+				return
+			}
+			go func() {
+				if t.dryRun {
+					t.AppendOutput("预演模式: 以下操作不会在设备上执行")
+				}
+				if err := t.newVersion(resultVer, t.flashTool()).PushConfig(sn, data); err != nil {
+					t.AppendOutput("推送配置失败: " + err.Error())
+					return
+				}
+				t.AppendOutput("推送配置成功!")
+			}()
+		}, t.window)
+	})
+
+	saveButton := widget.NewButton("保存设备配置到主机", func() {
+		if !lastResult() {
+			return
+		}
+
+		sn, ver, data := resultSN, resultVer, result.Device
+		save := func() {
+			if err := t.version.SaveDeviceConfig(sn, data); err != nil {
+				t.AppendOutput("保存设备配置失败: " + err.Error())
+				return
+			}
+			t.AppendOutput("设备配置已保存: " + version.SettingPath(ver, sn))
+		}
+
+		if !version.SettingExists(ver, sn) {
+			save()
+			return
+		}
+		dialog.ShowCustomConfirm("确认覆盖", "是", "否", widget.NewLabel(fmt.Sprintf("主机上已有设备 %s 的配置，确定使用设备上的配置覆盖吗？", sn)), func(b bool) {
+			if b {
+				save()
+			}
+		}, t.window)
+	})
+
+	return container.NewVBox(
+		fromErpCheck,
+		compareButton,
+		pushButton,
+		saveButton,
+	)
+}
@@ -0,0 +1,77 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"EMInit/pkg/utils"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DryRunTool 预演模式，只记录将要执行的命令和上传的文件，不在设备上执行
+// 只读查询(RunQuietCommand、StreamFromCommand)仍通过已建立的连接在设备上执行，用于核对SN、读取设备状态等
+type DryRunTool struct {
+	version.IFlashTool // 用于输出日志及只读查询
+}
+
+func NewDryRunTool(flashTool version.IFlashTool) *DryRunTool {
+	return &DryRunTool{IFlashTool: flashTool}
+}
+
+// DryRun 实现version.IDryRun，预演时不修改主机上的文件
+func (d *DryRunTool) DryRun() bool {
+	return true
+}
+
+// RunAndWaitCommand 记录将要执行的命令
+func (d *DryRunTool) RunAndWaitCommand(cmd string) (string, error) {
+	d.AppendOutput("[预演] 执行命令: " + cmd)
+	return "", nil
+}
+
+// UploadFile 记录将要上传的文件及其大小和hash值
+func (d *DryRunTool) UploadFile(localPath, remotePath string) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+
+	hash, err := utils.FileSHA256(localPath)
+	if err != nil {
+		return err
+	}
+
+	d.AppendOutput(fmt.Sprintf("[预演] 上传文件: %s -> %s, 大小: %d 字节, SHA256: %s", localPath, remotePath, info.Size(), hash))
+	return nil
+}
+
+// UploadContent 记录将要上传的内容大小和hash值
+func (d *DryRunTool) UploadContent(content []byte, remotePath string) error {
+	d.AppendOutput(fmt.Sprintf("[预演] 上传内容: %s, 大小: %d 字节, SHA256: %x", remotePath, len(content), sha256.Sum256(content)))
+	return nil
+}
+
+// StreamToCommand 生成将要传输的数据，只记录其大小和hash值
+func (d *DryRunTool) StreamToCommand(cmd string, write func(w io.Writer) error) error {
+	hash := sha256.New()
+	counter := &countingWriter{Writer: hash}
+	if err := write(counter); err != nil {
+		return err
+	}
+
+	d.AppendOutput(fmt.Sprintf("[预演] 流式传输到命令: %s, 大小: %d 字节, SHA256: %x", cmd, counter.n, hash.Sum(nil)))
+	return nil
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.Writer.Write(p)
+	c.n += int64(n)
+	return n, err
+}
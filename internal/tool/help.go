@@ -22,14 +22,36 @@ const helpContent = `EMInit.exe 工具使用帮助
   · 确保电脑主机连接到互联网。
   · 在工具中进入“更新管理”标签页。
   · 选择版本（V2/V3），并输入 EM500 设备的SN码，点击“下载初始配置”按钮，下载设备的初始配置信息，确保显示下载完成。
+  · 批量下载：进入“批量下载”标签页，粘贴SN列表（每行一个或用逗号分隔）或点击“导入文件”从文本文件导入，点击“开始下载”并发下载所有设备的初始配置。表格中显示每个SN的结果（成功、未注册、解密失败、HTTP错误、校验失败），点击“重试失败”只重新下载未成功的SN。
+  · 配置来源：下载的配置会校验ERP平台返回的MD5并检查内容格式，校验不通过时不会保存。每份配置旁会记录来源文件 setting/<版本>/<SN>.meta.json（下载地址、MD5校验结果、解密状态、下载时间及SHA256），用于追溯设备配置来源。
+  · 编辑配置（仅v3）：进入“配置编辑”标签页，输入设备SN后点击“加载”，可查看和修改已下载的初始配置（VPN、ERP平台、项目平台、数据上报、应用及串口）；点击“校验”检查MQTT地址、端口范围、应用名称等是否正确，“保存”时校验通过才会写入，避免错误配置刷写到设备。保存后配置标记为本地修改，之后重新下载ERP平台配置时会先备份本地配置。
+  · 串口配置（仅v3）：进入“串口配置”标签页，输入设备SN后点击“加载”，选择串口（如 /dev/ttyS0）可设置是否启用，也可添加或删除串口，点击“保存”校验后写入初始配置，串口按0/1保存。波特率、校验位、停止位及RS485模式暂不支持编辑，需确认设备端串口参数的字段名后再支持；配置中已有的参数原样保留并只读显示。启用串口时应用中需包含 cgService/serial。已连接设备时保存后会检查设备上是否存在这些串口，也可点击“检查设备串口”单独检查。
+  · 模板生成配置（仅v3）：ERP平台不可用或设备尚未注册时，可在“配置编辑”标签页选择 templates/v3 目录下的项目模板，点击“从模板创建”生成设备配置（模板中的 {{.SN}} 会替换为设备SN）。生成的配置标记为本地编写，之后下载ERP平台配置时，本地配置会先备份到 setting/v3 目录再覆盖，便于核对。
+  · 配置加密：设备配置中包含MQTT账号密码和VPN密钥，建议在“更新管理”标签页点击“主密码(加密配置)”设置主密码，之后 setting 目录下的配置均加密保存，只在上传到设备时在内存中解密。设置主密码后每次启动工具需先输入主密码，主密码遗忘后无法恢复，只能重新下载配置。
 4. 连接 EM500 设备到电脑主机：
   · 使用网线将电脑网口连接到 EM500 的 LAN2 口（初始默认LAN2口的IP地址为 192.168.2.136）。
   · 确认设备已正确连接并被工具识别（工具界面会显示设备已连接的提示）。
 5. 刷写设备
   · 在工具中进入“固件刷写”标签页。
   · 点击“开始刷写”按钮，开始刷写设备，工具会提示开始刷写过程。
+  · 刷写前会检查主机上该SN的初始配置：配置不存在或获取时间超过有效期（默认7天）时弹出提示，可“重新下载”最新配置，或确认后继续使用旧配置刷写。在“更新管理”标签页点击“配置有效期”可修改天数，并可设置配置缺失或过期时禁止刷写。
+  · SN核对：连接设备后工具读取设备上已有的SN（cgManager.yaml、frpc.toml，以及 /etc/device_sn、/etc/sn、/datas/sn 等SN标签文件），未输入SN时自动填入，不一致时提示。刷写、增量同步、差分更新及v2迁移前，会将输入的SN与设备上的SN核对，主机上没有该SN的初始配置时也会提示，需确认后才会继续；下载配置时ERP平台报告SN未注册也会提示核对SN，避免输错SN把错误的SN写入设备。
   · 确保刷写过程中设备连接稳定，等待刷写完成提示。
+  · 增量同步：点击“增量同步”按钮，工具对比设备上文件与本地文件的 SHA256，只传输有变化或缺失的程序、配置、frpc 及初始配置文件，适合重新刷写接近最新的设备；设备未安装程序时自动执行完整刷写。
+  · 差分更新（仅v3）：点击“差分更新”按钮，工具对比设备上已安装的程序与本地新版本，只上传差分补丁（本地存档 v3_archive 中有设备上的旧版本时）或有变化的文件，在设备上用 bspatch 还原并校验 SHA256，适合网络较慢的远程设备。
+  · v2迁移到v3：对已安装v2程序的设备，点击“从v2迁移到v3”按钮，工具会备份v2程序（设备目录 /datas/backup）、停止并禁用v2服务，将v2配置转换为v3配置（已下载v3配置时优先使用），然后安装v3程序并验证。
+  · 组件配置：刷写和增量同步时，工具以 v3_install/config、v2_install/config 中的组件配置为模板，自动写入网关型号和SN，再依次应用项目覆盖（templates/config/<版本>/project/<项目名>.yaml，未指定时使用 default.yaml）和设备覆盖（templates/config/<版本>/device/<SN>.yaml），可覆盖日志级别、切分大小、online_config_address、订阅主题、设备类型等，格式见 example.yaml。生成的配置会按YAML校验，校验失败时不会刷写。
+  · frpc配置：刷写和增量同步时，工具根据 templates/frpc 下的代理模板为每台设备生成 frpc.toml（服务器地址、认证token、SSH及可选的Web、Modbus代理，模板中的 CHANGE_ME 占位符需替换为实际的token或secretKey，代理名称由设备SN生成，SSH代理名称必须为设备SN，用于连接时核对SN），默认使用 default.toml，可在项目或设备覆盖文件中用 frpc: <模板名> 指定。生成的配置会按TOML校验，校验失败时不会刷写。
+  · 预演模式：勾选“预演模式”后，刷写和更新设置只列出将要执行的命令、上传的文件（大小、SHA256）、网络配置内容及是否重启，不会在设备上执行修改（核对SN、读取设备状态等只读查询仍需连接设备），可作为现场变更说明。
+  · 配置对比：连接设备后进入“配置对比”标签页，点击“对比配置”读取设备上正在使用的配置，与ERP平台（或主机上已下载）的配置按字段逐项对比并输出差异。对比后可“推送ERP配置到设备”（设备上原配置备份为 .bak 并重启服务），或“保存设备配置到主机”。
+  · 设备状态：连接设备后，“设备状态”标签页每10秒刷新一次设备的运行时间、平均负载、内存、/datas 磁盘使用、CPU温度、系统及内核版本、各网口IP，以及 cg* 和 frpc 服务的运行状态。磁盘或内存使用率超过90%、CPU温度超过80℃或服务未运行时，健康状态显示为异常及原因。
+  · 服务管理：“服务管理”标签页列出安装脚本创建的 cgKeepalive、frpc、cgCollector、cgUpdater 服务，点击“刷新服务状态”查看运行状态、是否开机启动、自动重启次数及状态变化时间。每个服务可启动、停止、重启、启用或禁用开机启动（操作前需确认，预演模式下只列出命令），点击“日志”查看 journalctl 中该服务最近的日志。
+  · 设备日志：在“设备日志”标签页点击“刷新”列出设备上 /datas/cf_go_v3/data/log 和 /datas/cf_go_v2/data/log 下的日志文件（含切分后的旧日志），选择文件后点击“开始查看”显示最近的日志，勾选“持续跟踪”时实时显示新写入的内容，再次点击“停止”结束。可按内容及最低级别（DEBUG/INFO/WARN/ERROR/FATAL）过滤，修改过滤条件后立即生效。
+  · 诊断包：点击“收集诊断包”，工具收集设备信息、运行状态、服务状态、各服务最近的 journal 日志、最近7天的组件日志及设备上的组件配置、frpc.toml 和网络配置，打包保存到 support/<SN>/<时间>.tar.gz，用于问题排查。配置中的 token、密码、WiFi密钥等字段会隐藏，不包含设备初始配置文件。日志及配置总大小超过200MB时超出部分不收集，并记录在诊断包的 errors.txt 中。
 6. 设置设备系统配置：
-  · 时间同步：EM500 设备无网络时，可将当前电脑主机的时间同步到设备，并写入硬件时钟。
-  · 网口配置：可配置 NET1/NET2 网口的（动态或静态）IP。
+  · 时间设置：在“设备管理”标签页点击“读取时间状态”查看设备当前时间、时区、同步服务及是否已同步，可从列表选择时区并填写NTP服务器（自动识别 chrony 或 systemd-timesyncd），点击“更新设置”生效。只有读取过时间状态且时区或NTP服务器与读取时不同才会修改，不会重启未修改的时间同步服务。勾选“设备无网络时同步主机时间”后，只有设备未与NTP服务器同步（timedatectl 显示未同步）时才会将主机时间（按UTC）同步到设备并写入硬件时钟。刷写时若已通过“更新设置”设置过时区（包括UTC）则保留，否则设备时区为UTC时默认设置为 Asia/Shanghai。
+  · 网口配置：在“设备管理”标签页点击“读取网络配置”读取设备上的 /etc/network/interfaces，选择接口后可修改开机启用、获取方式（dhcp/static）、多个地址（每行一个，如 192.168.2.136/24）、网关、DNS、MTU、静态路由（如 10.0.0.0/8 via 192.168.2.1）及VLAN父接口，也可添加（如 eth0.100）或删除接口。文件中无法识别的内容（注释、source、inet6等）会原样保留。点击“更新设置”时，配置有修改才会备份原文件、写入并重启设备。写入前会校验地址及子网掩码格式、网关是否在接口网段内、接口之间网段是否重叠、是否只有一个默认网关，校验失败时不会写入；修改后设备不再使用当前连接的IP时会提示连接将中断。
+  · 网络配置自动恢复：写入新的网络配置前，设备上会保存原配置并启用自动恢复服务。设备重启后，工具会通过新的地址重新连接并自动确认；若在“未确认时自动恢复”设定的分钟数（默认5分钟）内未能连接确认，设备会恢复原网络配置并再次重启，可使用原IP重新连接。新配置只有DHCP地址时，请手动连接设备后点击“确认网络配置”。
+  · 读取设备信息：在“设备管理”标签页点击“读取设备信息”，工具只读查询设备SN（cgManager.yaml、frpc.toml）、已安装的组件版本、配置文件SHA256、网口地址及网络配置、系统版本、内核及运行时间，保存到 inventory/<SN>/<时间>.json 用于现场巡检，并与主机上的程序和配置对比，提示是否需要刷写或更新。
+  · 电源操作：在“设备管理”标签页点击“重启设备”“关闭设备”或“重启全部服务”，确认后执行。重启设备后工具先等待设备SSH断开，再等待设备启动并自动重新连接，输出重启耗时；1分钟内未断开或5分钟内未恢复连接时提示失败，需检查设备电源及网络。关闭设备后需现场重新上电。“重启全部服务”重启已安装的 cgKeepalive、frpc、cgCollector、cgUpdater 服务，并检查重启后是否都在运行。
 `
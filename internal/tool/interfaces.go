@@ -0,0 +1,616 @@
+package tool
+
+import (
+	"EMInit/pkg/utils"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const interfacesPath = "/etc/network/interfaces"
+
+// NetRoute 静态路由
+type NetRoute struct {
+	Destination string // 目标网段，如 10.0.0.0/8
+	Gateway     string
+}
+
+func (r NetRoute) String() string {
+	return fmt.Sprintf("%s via %s", r.Destination, r.Gateway)
+}
+
+// NetInterface /etc/network/interfaces 中的一个 iface inet 配置
+type NetInterface struct {
+	Name          string
+	Auto          bool
+	Method        string   // dhcp、static、manual、loopback
+	Addresses     []string // CIDR格式，第一个为主地址
+	Gateway       string
+	DNS           []string
+	MTU           int
+	Routes        []NetRoute
+	VlanRawDevice string // VLAN子接口的父接口，如 eth0.100 的 eth0
+
+	lines []ifaceLine // 读取时各行的顺序，生成时按此顺序输出，未识别的选项及注释原样保留
+}
+
+// ifaceLine iface配置中的一行，field为空时为原样保留的内容
+type ifaceLine struct {
+	field string // 结构化字段，见ifaceFields
+	text  string
+}
+
+// ifaceFields 结构化字段，新增的字段按此顺序输出在最后
+var ifaceFields = []string{"vlan-raw-device", "address", "addrs", "gateway", "dns-nameservers", "mtu", "routes"}
+
+// stanzaKeywords 结束当前iface配置的关键字
+var stanzaKeywords = []string{"auto", "iface", "mapping", "source", "source-directory", "allow-auto", "allow-hotplug", "no-auto-down", "no-scripts"}
+
+var vlanPattern = regexp.MustCompile(`^(.+)\.(\d+)$`)
+
+// VlanID VLAN子接口的VLAN ID，不是VLAN子接口时返回0
+func (i *NetInterface) VlanID() int {
+	matches := vlanPattern.FindStringSubmatch(i.Name)
+	if len(matches) != 3 {
+		return 0
+	}
+	id, _ := strconv.Atoi(matches[2])
+	return id
+}
+
+// interfacesBlock 文件中的一段内容，iface为nil时原样保留raw
+type interfacesBlock struct {
+	iface *NetInterface
+	auto  []string // auto/allow-hotplug 行中的接口
+	raw   []string
+
+	attached bool // 与下一段之间没有空行，如下一段配置前的注释
+}
+
+// InterfacesFile 解析后的 /etc/network/interfaces，未识别的内容按原顺序保留
+type InterfacesFile struct {
+	blocks []*interfacesBlock
+}
+
+// ParseInterfaces 解析 /etc/network/interfaces 内容
+// 识别 iface <name> inet 配置中的地址、网关、DNS、MTU、静态路由及VLAN，其余内容(注释、source、inet6等)原样保留
+func ParseInterfaces(content string) (*InterfacesFile, error) {
+	f := &InterfacesFile{}
+	var current *NetInterface
+	var raw *interfacesBlock
+
+	appendRaw := func(line string) {
+		if raw == nil {
+			raw = &interfacesBlock{}
+			f.blocks = append(f.blocks, raw)
+		}
+		raw.raw = append(raw.raw, line)
+	}
+
+	// iface配置中的空行和注释，之后是该配置的选项时归入配置(空行不保留)，是下一段配置时原样保留
+	var pending []string
+	flushPending := func(toIface bool) {
+		for _, line := range pending {
+			if !toIface {
+				appendRaw(line)
+			} else if trimmed := strings.TrimSpace(line); trimmed != "" {
+				current.lines = append(current.lines, ifaceLine{text: trimmed})
+			}
+		}
+		pending = nil
+	}
+
+	// 新的一段开始时，紧接其前的未识别内容与之相连
+	startBlock := func() {
+		if raw != nil && len(raw.raw) > 0 && strings.TrimSpace(raw.raw[len(raw.raw)-1]) != "" {
+			raw.attached = true
+		}
+		current, raw = nil, nil
+	}
+
+	for n, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		fields := strings.Fields(trimmed)
+		if len(fields) == 0 || strings.HasPrefix(trimmed, "#") {
+			// iface配置到下一个auto、iface等关键字才结束
+			if current != nil {
+				pending = append(pending, line)
+			} else {
+				appendRaw(line)
+			}
+			continue
+		}
+
+		if current != nil {
+			flushPending(!utils.ContainsString(stanzaKeywords, fields[0]))
+		}
+		switch fields[0] {
+		case "auto":
+			startBlock()
+			f.blocks = append(f.blocks, &interfacesBlock{auto: fields[1:]})
+			continue
+		case "iface":
+			startBlock()
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("第%d行: iface 格式错误: %s", n+1, trimmed)
+			}
+			if fields[2] != "inet" {
+				// inet6等其他地址族原样保留
+				appendRaw(line)
+				continue
+			}
+			current = &NetInterface{Name: fields[1], Method: fields[3]}
+			f.blocks = append(f.blocks, &interfacesBlock{iface: current})
+			continue
+		case "mapping", "source", "source-directory", "allow-auto", "allow-hotplug", "no-auto-down", "no-scripts":
+			current = nil
+			appendRaw(line)
+			continue
+		}
+
+		if current == nil {
+			appendRaw(line)
+			continue
+		}
+		if err := current.parseOption(fields); err != nil {
+			return nil, fmt.Errorf("第%d行: %v", n+1, err)
+		}
+	}
+	if current != nil {
+		flushPending(false)
+	}
+
+	// auto行中的接口合并到对应的iface
+	for _, block := range f.blocks {
+		if block.auto == nil {
+			continue
+		}
+		var rest []string
+		for _, name := range block.auto {
+			if iface := f.Interface(name); iface != nil {
+				iface.Auto = true
+			} else {
+				rest = append(rest, name)
+			}
+		}
+		block.auto = rest
+	}
+
+	// 去掉文件末尾的空行
+	if last := len(f.blocks) - 1; last >= 0 && f.blocks[last].raw != nil {
+		lines := f.blocks[last].raw
+		for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		f.blocks[last].raw = lines
+	}
+
+	return f, nil
+}
+
+func (i *NetInterface) parseOption(fields []string) error {
+	key, value := fields[0], strings.Join(fields[1:], " ")
+	field := key
+	switch key {
+	case "address":
+		// 不带前缀的主地址由之后的netmask补齐
+		i.Addresses = append(i.Addresses, value)
+	case "netmask":
+		if len(i.Addresses) == 0 {
+			return fmt.Errorf("netmask 前缺少 address")
+		}
+		prefix, err := netmaskPrefix(value)
+		if err != nil {
+			return err
+		}
+		field = "address"
+		// 主地址已是CIDR格式时，前缀一致的netmask是多余的
+		if _, existing, ok := strings.Cut(i.Addresses[0], "/"); ok {
+			if existing != strconv.Itoa(prefix) {
+				return fmt.Errorf("netmask `%s` 与地址 `%s` 的前缀不一致", value, i.Addresses[0])
+			}
+			break
+		}
+		i.Addresses[0] = fmt.Sprintf("%s/%d", i.Addresses[0], prefix)
+	case "gateway":
+		i.Gateway = value
+	case "dns-nameservers":
+		i.DNS = append(i.DNS, fields[1:]...)
+	case "mtu":
+		mtu, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("mtu `%s` 不是有效的数字", value)
+		}
+		i.MTU = mtu
+	case "vlan-raw-device":
+		i.VlanRawDevice = value
+	case "up", "post-up":
+		if addr, ok := i.parseIPCommand(fields[1:], "addr", "add"); ok && len(addr) == 1 && strings.Contains(addr[0], "/") {
+			i.Addresses = append(i.Addresses, addr[0])
+			field = "addrs"
+			break
+		}
+		if route, ok := i.parseIPCommand(fields[1:], "route", "add"); ok && len(route) == 3 && route[1] == "via" {
+			i.Routes = append(i.Routes, NetRoute{Destination: route[0], Gateway: route[2]})
+			field = "routes"
+			break
+		}
+		field = ""
+	case "down", "pre-down":
+		// 与up对应的删除地址和路由命令由生成时补齐
+		if addr, ok := i.parseIPCommand(fields[1:], "addr", "del"); ok && len(addr) == 1 && strings.Contains(addr[0], "/") {
+			field = "addrs"
+			break
+		}
+		if route, ok := i.parseIPCommand(fields[1:], "route", "del"); ok && len(route) == 3 && route[1] == "via" {
+			field = "routes"
+			break
+		}
+		field = ""
+	default:
+		field = ""
+	}
+
+	if field == "" {
+		i.lines = append(i.lines, ifaceLine{text: strings.Join(fields, " ")})
+	} else {
+		i.lines = append(i.lines, ifaceLine{field: field})
+	}
+	return nil
+}
+
+// parseIPCommand 解析 ip <object> <action> ... [dev <name>]，返回去掉dev后的参数，dev不是当前接口时不识别
+func (i *NetInterface) parseIPCommand(fields []string, object, action string) ([]string, bool) {
+	if len(fields) < 4 || fields[0] != "ip" || fields[1] != object || fields[2] != action {
+		return nil, false
+	}
+	args := fields[3:]
+	if len(args) >= 2 && args[len(args)-2] == "dev" {
+		if args[len(args)-1] != i.Name {
+			return nil, false
+		}
+		args = args[:len(args)-2]
+	}
+	if len(args) == 0 {
+		return nil, false
+	}
+	return args, true
+}
+
+// Interfaces 文件中所有 iface inet 配置
+func (f *InterfacesFile) Interfaces() []*NetInterface {
+	var ifaces []*NetInterface
+	for _, block := range f.blocks {
+		if block.iface != nil {
+			ifaces = append(ifaces, block.iface)
+		}
+	}
+	return ifaces
+}
+
+// Interface 按名称查找接口配置，不存在时返回nil
+func (f *InterfacesFile) Interface(name string) *NetInterface {
+	for _, block := range f.blocks {
+		if block.iface != nil && block.iface.Name == name {
+			return block.iface
+		}
+	}
+	return nil
+}
+
+// AddInterface 添加接口配置，VLAN子接口自动设置父接口
+func (f *InterfacesFile) AddInterface(name string) (*NetInterface, error) {
+	if f.Interface(name) != nil {
+		return nil, fmt.Errorf("接口 %s 已存在", name)
+	}
+	iface := &NetInterface{Name: name, Auto: true, Method: "dhcp"}
+	if matches := vlanPattern.FindStringSubmatch(name); len(matches) == 3 {
+		iface.VlanRawDevice = matches[1]
+	}
+	f.blocks = append(f.blocks, &interfacesBlock{iface: iface})
+	return iface, nil
+}
+
+// RemoveInterface 删除接口配置
+func (f *InterfacesFile) RemoveInterface(name string) {
+	for i, block := range f.blocks {
+		if block.iface != nil && block.iface.Name == name {
+			f.blocks = append(f.blocks[:i], f.blocks[i+1:]...)
+			return
+		}
+	}
+}
+
+// String 生成 /etc/network/interfaces 内容
+func (f *InterfacesFile) String() string {
+	var b strings.Builder
+	separator := ""
+	write := func(part string, attached bool) {
+		b.WriteString(separator)
+		b.WriteString(part)
+		separator = "\n\n"
+		if attached {
+			separator = "\n"
+		}
+	}
+	for _, block := range f.blocks {
+		switch {
+		case block.iface != nil:
+			write(block.iface.String(), false)
+		case block.auto != nil:
+			if len(block.auto) > 0 {
+				write("auto "+strings.Join(block.auto, " "), false)
+			}
+		default:
+			// 未识别的内容原样保留，去掉首尾空行后由块间空行分隔，紧接下一段的内容不加空行
+			lines := block.raw
+			for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+				lines = lines[1:]
+			}
+			for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+			if len(lines) > 0 {
+				write(strings.Join(lines, "\n"), block.attached)
+			}
+		}
+	}
+	return b.String() + "\n"
+}
+
+// String 生成单个接口的配置，读取时的各行保持原顺序，新增的字段输出在最后
+func (i *NetInterface) String() string {
+	var lines []string
+	if i.Auto {
+		lines = append(lines, "auto "+i.Name)
+	}
+	lines = append(lines, fmt.Sprintf("iface %s inet %s", i.Name, i.Method))
+
+	add := func(key, value string) {
+		lines = append(lines, fmt.Sprintf("    %s %s", key, value))
+	}
+	emitted := make(map[string]bool)
+	emit := func(field string) {
+		if emitted[field] {
+			return
+		}
+		emitted[field] = true
+
+		switch field {
+		case "vlan-raw-device":
+			if i.VlanRawDevice != "" {
+				add("vlan-raw-device", i.VlanRawDevice)
+			}
+		case "address":
+			if len(i.Addresses) == 0 || i.Method != "static" {
+				return
+			}
+			ip, mask, err := splitCIDR(i.Addresses[0])
+			if err != nil {
+				add("address", i.Addresses[0])
+				return
+			}
+			add("address", ip)
+			add("netmask", mask)
+		case "addrs":
+			for n, address := range i.Addresses {
+				if n == 0 && i.Method == "static" {
+					continue
+				}
+				// 附加地址通过ip命令添加，兼容不支持多个address的ifupdown
+				add("up", fmt.Sprintf("ip addr add %s dev %s", address, i.Name))
+				add("down", fmt.Sprintf("ip addr del %s dev %s", address, i.Name))
+			}
+		case "gateway":
+			if i.Gateway != "" {
+				add("gateway", i.Gateway)
+			}
+		case "dns-nameservers":
+			if len(i.DNS) > 0 {
+				add("dns-nameservers", strings.Join(i.DNS, " "))
+			}
+		case "mtu":
+			if i.MTU > 0 {
+				add("mtu", strconv.Itoa(i.MTU))
+			}
+		case "routes":
+			for _, route := range i.Routes {
+				add("up", fmt.Sprintf("ip route add %s via %s dev %s", route.Destination, route.Gateway, i.Name))
+				add("down", fmt.Sprintf("ip route del %s via %s dev %s", route.Destination, route.Gateway, i.Name))
+			}
+		}
+	}
+
+	for _, line := range i.lines {
+		if line.field == "" {
+			lines = append(lines, "    "+line.text)
+			continue
+		}
+		emit(line.field)
+	}
+	for _, field := range ifaceFields {
+		emit(field)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitCIDR 将 192.168.2.136/24 拆分为地址和子网掩码
+func splitCIDR(address string) (string, string, error) {
+	ip, ipNet, err := net.ParseCIDR(address)
+	if err != nil {
+		return "", "", err
+	}
+	mask := ipNet.Mask
+	if len(mask) != net.IPv4len {
+		return "", "", fmt.Errorf("`%s` 不是IPv4地址", address)
+	}
+	return ip.String(), net.IP(mask).String(), nil
+}
+
+// netmaskPrefix 子网掩码转换为前缀长度，如 255.255.255.0 为 24
+func netmaskPrefix(netmask string) (int, error) {
+	if prefix, err := strconv.Atoi(netmask); err == nil && prefix >= 0 && prefix <= 32 {
+		return prefix, nil
+	}
+	ip := net.ParseIP(netmask).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("子网掩码 `%s` 无效", netmask)
+	}
+	ones, bits := net.IPMask(ip).Size()
+	if bits == 0 {
+		return 0, fmt.Errorf("子网掩码 `%s` 不连续", netmask)
+	}
+	return ones, nil
+}
+
+// Validate 校验接口地址、子网掩码、网关、DNS、MTU、静态路由，以及接口间的网段冲突和默认网关数量
+func (f *InterfacesFile) Validate() []error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	type subnet struct {
+		iface string
+		net   *net.IPNet
+	}
+	var subnets []subnet
+	var gateways []string
+
+	ifaces := f.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Method == "loopback" {
+			continue
+		}
+		label := "接口 " + iface.Name
+		if !utils.ContainsString(interfaceMethods, iface.Method) {
+			add("%s 获取方式 `%s` 无效，可选值: %s", label, iface.Method, strings.Join(interfaceMethods, ", "))
+		}
+		if iface.Method == "static" && len(iface.Addresses) == 0 {
+			add("%s 为静态地址，至少需要一个地址", label)
+		}
+
+		var own []*net.IPNet
+		for _, address := range iface.Addresses {
+			ipNet, err := parseHostCIDR(address)
+			if err != nil {
+				add("%s %v", label, err)
+				continue
+			}
+			own = append(own, ipNet)
+			for _, other := range subnets {
+				if other.net.Contains(ipNet.IP) || ipNet.Contains(other.net.IP) {
+					add("%s 的地址 %s 与接口 %s 的网段 %s 重叠", label, address, other.iface, other.net)
+				}
+			}
+		}
+		for _, ipNet := range own {
+			subnets = append(subnets, subnet{iface: iface.Name, net: &net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask}})
+		}
+
+		if iface.Gateway != "" {
+			gateways = append(gateways, iface.Name)
+			gateway := net.ParseIP(iface.Gateway).To4()
+			switch {
+			case gateway == nil:
+				add("%s 网关 `%s` 不是有效的IPv4地址", label, iface.Gateway)
+			case iface.Method == "static" && !containsIP(own, gateway):
+				add("%s 网关 %s 不在接口的网段内", label, iface.Gateway)
+			}
+		}
+		for _, dns := range iface.DNS {
+			if net.ParseIP(dns).To4() == nil {
+				add("%s DNS `%s` 不是有效的IPv4地址", label, dns)
+			}
+		}
+		if iface.MTU != 0 && (iface.MTU < 576 || iface.MTU > 9000) {
+			add("%s MTU %d 超出范围(576-9000)", label, iface.MTU)
+		}
+		if iface.VlanID() > 4094 {
+			add("%s VLAN ID %d 超出范围(1-4094)", label, iface.VlanID())
+		}
+		if iface.VlanID() > 0 && iface.VlanRawDevice == "" {
+			add("%s 为VLAN子接口，需指定父接口", label)
+		}
+	}
+
+	// 静态路由的网关需在某个接口的网段内
+	for _, iface := range ifaces {
+		for _, route := range iface.Routes {
+			if _, _, err := net.ParseCIDR(route.Destination); err != nil {
+				add("接口 %s 静态路由目标 `%s` 不是有效的网段，如 10.0.0.0/8", iface.Name, route.Destination)
+			}
+			gateway := net.ParseIP(route.Gateway).To4()
+			if gateway == nil {
+				add("接口 %s 静态路由网关 `%s` 不是有效的IPv4地址", iface.Name, route.Gateway)
+				continue
+			}
+			reachable := false
+			for _, s := range subnets {
+				if s.iface == iface.Name && s.net.Contains(gateway) {
+					reachable = true
+				}
+			}
+			if !reachable && iface.Method == "static" {
+				add("接口 %s 静态路由网关 %s 不在接口的网段内", iface.Name, route.Gateway)
+			}
+		}
+	}
+
+	if len(gateways) > 1 {
+		add("只能有一个默认网关，当前接口 %s 都配置了网关", strings.Join(gateways, "、"))
+	}
+	return errs
+}
+
+// StaticAddress 配置中开机启用的静态地址是否包含ip
+func (f *InterfacesFile) StaticAddress(ip string) bool {
+	for _, iface := range f.Interfaces() {
+		if !iface.Auto || iface.Method != "static" {
+			continue
+		}
+		for _, address := range iface.Addresses {
+			if addr, _, err := net.ParseCIDR(address); err == nil && addr.String() == ip {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseHostCIDR 解析接口地址，要求为带前缀的IPv4地址，且不是网络地址或广播地址
+func parseHostCIDR(address string) (*net.IPNet, error) {
+	if !strings.Contains(address, "/") {
+		return nil, fmt.Errorf("地址 `%s` 缺少前缀长度，如 %s/24", address, address)
+	}
+	ip, ipNet, err := net.ParseCIDR(address)
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Errorf("地址 `%s` 不是有效的IPv4 CIDR", address)
+	}
+
+	ones, _ := ipNet.Mask.Size()
+	if ones == 0 {
+		return nil, fmt.Errorf("地址 %s 的子网掩码无效", address)
+	}
+	if ones < 31 {
+		broadcast := make(net.IP, net.IPv4len)
+		for i := range broadcast {
+			broadcast[i] = ipNet.IP.To4()[i] | ^ipNet.Mask[i]
+		}
+		if ip.Equal(ipNet.IP) || ip.Equal(broadcast) {
+			return nil, fmt.Errorf("地址 %s 是网络地址或广播地址，不能作为接口地址", address)
+		}
+	}
+	return &net.IPNet{IP: ip.To4(), Mask: ipNet.Mask}, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
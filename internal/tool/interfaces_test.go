@@ -0,0 +1,203 @@
+package tool
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseInterfacesNetmask(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		address string
+		wantErr bool
+	}{
+		{"地址加netmask", "address 192.168.2.136\n    netmask 255.255.255.0", "192.168.2.136/24", false},
+		{"CIDR地址", "address 192.168.2.136/24", "192.168.2.136/24", false},
+		{"CIDR地址加相同netmask", "address 192.168.2.136/24\n    netmask 255.255.255.0", "192.168.2.136/24", false},
+		{"CIDR地址加不同netmask", "address 192.168.2.136/24\n    netmask 255.255.0.0", "", true},
+		{"缺少address", "netmask 255.255.255.0", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseInterfaces("auto eth0\niface eth0 inet static\n    " + tt.options + "\n")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("应返回错误")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			iface := f.Interface("eth0")
+			if len(iface.Addresses) != 1 || iface.Addresses[0] != tt.address {
+				t.Errorf("地址 = %v, 期望 %s", iface.Addresses, tt.address)
+			}
+		})
+	}
+}
+
+func TestParseInterfacesBlankLine(t *testing.T) {
+	content := `auto lo
+iface lo inet loopback
+
+auto eth0
+iface eth0 inet static
+    address 192.168.2.136
+
+    netmask 255.255.255.0
+    gateway 192.168.2.1
+
+source /etc/network/interfaces.d/*
+`
+	f, err := ParseInterfaces(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iface := f.Interface("eth0")
+	if iface.Addresses[0] != "192.168.2.136/24" || iface.Gateway != "192.168.2.1" {
+		t.Errorf("空行后的选项未归入eth0: %+v", iface)
+	}
+
+	out := f.String()
+	want := `auto lo
+iface lo inet loopback
+
+auto eth0
+iface eth0 inet static
+    address 192.168.2.136
+    netmask 255.255.255.0
+    gateway 192.168.2.1
+
+source /etc/network/interfaces.d/*
+`
+	if out != want {
+		t.Errorf("生成内容:\n%s\n期望:\n%s", out, want)
+	}
+	if _, err := ParseInterfaces(out); err != nil || strings.Count(out, "iface") != 2 {
+		t.Errorf("重新解析失败: %v", err)
+	}
+}
+
+// 不是 <cidr> [dev 当前接口] 格式的ip命令原样保留
+func TestParseInterfacesKeepsIPCommands(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		addresses int
+	}{
+		{"附加地址", "up ip addr add 10.0.0.5/24 dev eth0", 2},
+		{"带broadcast", "up ip addr add 10.0.0.5/24 broadcast 10.0.0.255 dev eth0", 1},
+		{"其他接口", "up ip addr add 10.0.0.5/24 dev eth1", 1},
+		{"不带前缀", "up ip addr add 10.0.0.5 dev eth0", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "auto eth0\niface eth0 inet static\n    address 192.168.2.136/24\n    " + tt.line + "\n"
+			f, err := ParseInterfaces(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := len(f.Interface("eth0").Addresses); n != tt.addresses {
+				t.Errorf("地址数量 = %d, 期望 %d", n, tt.addresses)
+			}
+			if tt.addresses == 1 && !strings.Contains(f.String(), tt.line) {
+				t.Errorf("未原样保留 `%s`:\n%s", tt.line, f.String())
+			}
+		})
+	}
+}
+
+// 注释保持原位置，下一段配置前的注释不归入上一个接口
+func TestParseInterfacesComments(t *testing.T) {
+	content := `auto eth0
+iface eth0 inet static
+    # 主地址
+    address 192.168.2.136
+    netmask 255.255.255.0
+    # 默认网关
+    gateway 192.168.2.1
+    hwaddress ether 00:11:22:33:44:55
+
+# wifi section
+auto wlan0
+iface wlan0 inet dhcp
+`
+	f, err := ParseInterfaces(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := f.String(); out != content {
+		t.Errorf("生成内容:\n%s\n期望:\n%s", out, content)
+	}
+
+	// 修改字段后仍在原位置，新增字段在最后
+	eth0 := f.Interface("eth0")
+	eth0.Gateway = "192.168.2.254"
+	eth0.MTU = 1400
+	want := `auto eth0
+iface eth0 inet static
+    # 主地址
+    address 192.168.2.136
+    netmask 255.255.255.0
+    # 默认网关
+    gateway 192.168.2.254
+    hwaddress ether 00:11:22:33:44:55
+    mtu 1400
+`
+	if out := eth0.String() + "\n"; out != want {
+		t.Errorf("生成内容:\n%s\n期望:\n%s", out, want)
+	}
+}
+
+func TestInterfacesValidate(t *testing.T) {
+	const lan = "auto eth1\niface eth1 inet static\n    address 192.168.2.136/24\n"
+	tests := []struct {
+		name    string
+		content string
+		wantErr string // 为空时应校验通过
+	}{
+		{"单个静态地址", lan, ""},
+		{"DHCP接口", "auto eth0\niface eth0 inet dhcp\n", ""},
+		{"网关在网段内", lan + "    gateway 192.168.2.1\n", ""},
+		{"网关不在网段内", lan + "    gateway 192.168.3.1\n", "不在接口的网段内"},
+		{"无效网关", lan + "    gateway 192.168.2\n", "不是有效的IPv4地址"},
+		{"两个默认网关", lan + "    gateway 192.168.2.1\n\nauto eth0\niface eth0 inet static\n    address 10.0.0.10/24\n    gateway 10.0.0.1\n", "只能有一个默认网关"},
+		{"DHCP与静态各一个网关", lan + "    gateway 192.168.2.1\n\nauto eth0\niface eth0 inet dhcp\n    gateway 10.0.0.1\n", "只能有一个默认网关"},
+		{"接口网段重叠", lan + "\nauto eth0\niface eth0 inet static\n    address 192.168.2.10/24\n", "重叠"},
+		{"大网段包含小网段", lan + "\nauto eth0\niface eth0 inet static\n    address 192.168.0.10/16\n", "重叠"},
+		{"不同网段", lan + "\nauto eth0\niface eth0 inet static\n    address 192.168.3.10/24\n", ""},
+		{"网络地址", "auto eth1\niface eth1 inet static\n    address 192.168.2.0/24\n", "网络地址或广播地址"},
+		{"广播地址", "auto eth1\niface eth1 inet static\n    address 192.168.2.255/24\n", "网络地址或广播地址"},
+		{"/31点对点地址", "auto eth1\niface eth1 inet static\n    address 10.0.0.0/31\n", ""},
+		{"静态接口缺少地址", "auto eth1\niface eth1 inet static\n    mtu 1500\n", "至少需要一个地址"},
+		{"MTU超出范围", lan + "    mtu 100\n", "MTU 100 超出范围"},
+		{"无效DNS", lan + "    dns-nameservers 8.8.8.8 dns.google\n", "DNS `dns.google`"},
+		{"静态路由网关不在网段内", lan + "    up ip route add 10.0.0.0/8 via 172.16.0.1\n", "静态路由网关 172.16.0.1 不在接口的网段内"},
+		{"静态路由", lan + "    up ip route add 10.0.0.0/8 via 192.168.2.1\n", ""},
+		{"VLAN子接口", "auto eth0.100\niface eth0.100 inet static\n    address 10.1.0.2/24\n    vlan-raw-device eth0\n", ""},
+		{"VLAN ID超出范围", "auto eth0.5000\niface eth0.5000 inet static\n    address 10.1.0.2/24\n    vlan-raw-device eth0\n", "VLAN ID 5000 超出范围"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseInterfaces(tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			errs := f.Validate()
+			if tt.wantErr == "" {
+				if len(errs) > 0 {
+					t.Errorf("应校验通过, 实际: %v", errs)
+				}
+				return
+			}
+			for _, err := range errs {
+				if strings.Contains(err.Error(), tt.wantErr) {
+					return
+				}
+			}
+			t.Errorf("应包含错误 %q, 实际: %v", tt.wantErr, errs)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"fmt"
+)
+
+// readInventory 读取设备的身份及状态，保存快照到主机并给出是否需要刷写的建议
+func (t *FirmwareFlashTool) readInventory() {
+	t.AppendOutput("开始读取设备信息...")
+	inv, err := version.CollectInventory(t)
+	if err != nil {
+		t.AppendOutput("读取设备信息失败: " + err.Error())
+		return
+	}
+	t.AppendOutput(inv.String())
+
+	file, err := version.SaveInventory(inv)
+	if err != nil {
+		t.AppendOutput("保存设备信息失败: " + err.Error())
+	} else {
+		t.AppendOutput("设备信息已保存到 " + file)
+	}
+
+	advice := inv.Advise(t.snEntry.Text)
+	if len(advice) == 0 {
+		t.AppendOutput("设备程序及配置与主机一致，无需刷写")
+		return
+	}
+	for _, item := range advice {
+		t.AppendOutput(fmt.Sprintf("建议: %s", item))
+	}
+}
@@ -0,0 +1,206 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"EMInit/pkg/utils"
+	"context"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultLogLines = 200  // 开始查看时读取的行数
+	maxLogLines     = 5000 // 查看器中保留的最大行数
+	allLogLevels    = "全部"
+)
+
+// LogViewer 设备日志查看，支持持续跟踪及按内容、级别过滤
+type LogViewer struct {
+	tool *FirmwareFlashTool
+
+	mu     sync.Mutex
+	lines  []string           // 读取到的日志行，过滤条件变化时重新过滤
+	cancel context.CancelFunc // 停止当前的日志读取
+
+	fileSelect  *widget.Select
+	linesEntry  *widget.Entry
+	filterEntry *widget.Entry
+	levelSelect *widget.Select
+	followCheck *widget.Check
+	startButton *widget.Button
+	logText     *widget.Entry
+}
+
+func NewLogViewer(t *FirmwareFlashTool) *LogViewer {
+	v := &LogViewer{
+		tool:        t,
+		fileSelect:  widget.NewSelect(nil, nil),
+		linesEntry:  widget.NewEntry(),
+		filterEntry: widget.NewEntry(),
+		followCheck: widget.NewCheck("持续跟踪", nil),
+		logText:     widget.NewMultiLineEntry(),
+	}
+	v.fileSelect.PlaceHolder = "请先刷新日志文件列表"
+	v.linesEntry.SetText(strconv.Itoa(defaultLogLines))
+	v.filterEntry.SetPlaceHolder("只显示包含该内容的行")
+	v.filterEntry.OnChanged = func(string) { v.render() }
+	v.levelSelect = widget.NewSelect(append([]string{allLogLevels}, version.LogLevels...), func(string) { v.render() })
+	v.levelSelect.SetSelected(allLogLevels)
+	v.followCheck.SetChecked(true)
+	v.startButton = widget.NewButton("开始查看", v.toggle)
+	v.logText.Wrapping = fyne.TextWrapOff
+	v.logText.TextStyle = fyne.TextStyle{Monospace: true}
+	return v
+}
+
+// Content 日志查看区域
+func (v *LogViewer) Content() fyne.CanvasObject {
+	form := widget.NewForm(
+		widget.NewFormItem("日志文件", container.NewBorder(nil, nil, nil, widget.NewButton("刷新", func() {
+			go v.loadFiles()
+		}), v.fileSelect)),
+		widget.NewFormItem("最近行数", v.linesEntry),
+		widget.NewFormItem("内容过滤", v.filterEntry),
+		widget.NewFormItem("最低级别", v.levelSelect),
+	)
+
+	buttons := container.NewHBox(
+		v.followCheck,
+		v.startButton,
+		widget.NewButton("清空", func() {
+			v.mu.Lock()
+			v.lines = nil
+			v.mu.Unlock()
+			v.render()
+		}),
+		widget.NewButton("收集诊断包", func() {
+			go v.tool.collectSupportBundle()
+		}),
+	)
+
+	return container.NewBorder(container.NewVBox(form, buttons), nil, nil, nil, v.logText)
+}
+
+// loadFiles 读取设备上的日志文件列表
+func (v *LogViewer) loadFiles() {
+	files, err := version.ListLogFiles(v.tool)
+	if err != nil {
+		v.tool.AppendOutput("读取日志文件列表失败: " + err.Error())
+		return
+	}
+	if len(files) == 0 {
+		v.tool.AppendOutput("设备上没有日志文件")
+	}
+	v.fileSelect.Options = files
+	v.fileSelect.PlaceHolder = "选择日志文件"
+	if len(files) > 0 && !utils.ContainsString(files, v.fileSelect.Selected) {
+		v.fileSelect.SetSelected(files[0])
+	}
+	v.fileSelect.Refresh()
+}
+
+// toggle 开始或停止查看日志
+func (v *LogViewer) toggle() {
+	v.mu.Lock()
+	cancel := v.cancel
+	v.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		return
+	}
+
+	file := v.fileSelect.Selected
+	if file == "" {
+		v.tool.AppendOutput("请先选择日志文件")
+		return
+	}
+	lines, err := strconv.Atoi(strings.TrimSpace(v.linesEntry.Text))
+	if err != nil || lines <= 0 {
+		lines = defaultLogLines
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	v.mu.Lock()
+	v.lines = nil
+	v.cancel = cancel
+	v.mu.Unlock()
+	v.startButton.SetText("停止")
+	v.render()
+
+	go func() {
+		defer func() {
+			cancel()
+			v.mu.Lock()
+			v.cancel = nil
+			v.mu.Unlock()
+			v.startButton.SetText("开始查看")
+		}()
+
+		cmd := version.TailLogCommand(file, lines, v.followCheck.Checked)
+		err := v.tool.StreamCommand(ctx, cmd, v.appendLine)
+		if err != nil {
+			v.tool.AppendOutput("读取日志失败: " + err.Error())
+		}
+	}()
+}
+
+func (v *LogViewer) appendLine(line string) {
+	v.mu.Lock()
+	v.lines = append(v.lines, line)
+	// 超出上限一定行数后再裁剪，避免每行都重新显示
+	trimmed := len(v.lines) > maxLogLines+maxLogLines/10
+	if trimmed {
+		v.lines = v.lines[len(v.lines)-maxLogLines:]
+	}
+	v.mu.Unlock()
+
+	if trimmed {
+		v.render()
+		return
+	}
+	if v.match(line) {
+		v.logText.Append(line + "\n")
+		v.logText.CursorRow = strings.Count(v.logText.Text, "\n")
+	}
+}
+
+func (v *LogViewer) match(line string) bool {
+	level := v.levelSelect.Selected
+	if level == allLogLevels {
+		level = ""
+	}
+	return version.MatchLogLine(line, strings.TrimSpace(v.filterEntry.Text), level)
+}
+
+// render 按当前过滤条件重新显示日志
+func (v *LogViewer) render() {
+	v.mu.Lock()
+	lines := make([]string, 0, len(v.lines))
+	for _, line := range v.lines {
+		if v.match(line) {
+			lines = append(lines, line)
+		}
+	}
+	v.mu.Unlock()
+
+	text := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		text += "\n"
+	}
+	v.logText.SetText(text)
+}
+
+// collectSupportBundle 收集设备日志、配置及服务状态，保存诊断包到主机
+func (t *FirmwareFlashTool) collectSupportBundle() {
+	t.AppendOutput("开始收集诊断包...")
+	file, err := version.CollectSupportBundle(t, strings.TrimSpace(t.snEntry.Text))
+	if err != nil {
+		t.AppendOutput("收集诊断包失败: " + err.Error())
+		return
+	}
+	t.AppendOutput("诊断包已保存到 " + file)
+}
@@ -0,0 +1,53 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showMasterPasswordDialog 输入主密码解锁设备配置，首次使用时设置主密码
+func (t *FirmwareFlashTool) showMasterPasswordDialog() {
+	enabled := version.SettingEncryptionEnabled()
+
+	passwordEntry := widget.NewPasswordEntry()
+	confirmEntry := widget.NewPasswordEntry()
+	items := []*widget.FormItem{widget.NewFormItem("主密码", passwordEntry)}
+	title := "解锁设备配置"
+	if !enabled {
+		title = "设置主密码"
+		items = append(items, widget.NewFormItem("确认主密码", confirmEntry))
+	}
+
+	dialog.ShowForm(title, "确定", "取消", items, func(ok bool) {
+		if !ok {
+			if !enabled {
+				t.AppendOutput("未设置主密码，设备配置将以明文保存")
+			}
+			return
+		}
+
+		if !enabled && passwordEntry.Text != confirmEntry.Text {
+			dialog.ShowError(errors.New("两次输入的主密码不一致"), t.window)
+			return
+		}
+
+		if err := version.UnlockSettings(passwordEntry.Text); err != nil {
+			dialog.ShowError(err, t.window)
+			return
+		}
+		t.AppendOutput("设备配置已解锁")
+
+		// 加密之前以明文保存的配置
+		count, err := version.EncryptAllSettings()
+		if err != nil {
+			t.AppendOutput("加密设备配置失败: " + err.Error())
+			return
+		}
+		if count > 0 {
+			t.AppendOutput(fmt.Sprintf("已加密 %d 个明文保存的设备配置", count))
+		}
+	}, t.window)
+}
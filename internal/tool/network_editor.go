@@ -0,0 +1,327 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var interfaceMethods = []string{"dhcp", "static", "manual", "loopback"}
+
+// NetworkEditor 设备网络配置(/etc/network/interfaces)编辑器
+type NetworkEditor struct {
+	tool     *FirmwareFlashTool
+	file     *InterfacesFile // 从设备读取的配置
+	original string          // 读取时的配置内容，用于判断是否修改
+	current  *NetInterface   // 当前编辑的接口
+
+	ifaceSelect    *widget.Select
+	autoCheck      *widget.Check
+	methodSelect   *widget.Select
+	addressesEntry *widget.Entry
+	gatewayEntry   *widget.Entry
+	dnsEntry       *widget.Entry
+	mtuEntry       *widget.Entry
+	routesEntry    *widget.Entry
+	vlanEntry      *widget.Entry
+	rollbackEntry  *widget.Entry // 自动恢复时间(分钟)
+	form           *widget.Form
+}
+
+func NewNetworkEditor(t *FirmwareFlashTool) *NetworkEditor {
+	e := &NetworkEditor{
+		tool:           t,
+		autoCheck:      widget.NewCheck("开机启用(auto)", nil),
+		methodSelect:   widget.NewSelect(interfaceMethods, nil),
+		addressesEntry: widget.NewMultiLineEntry(),
+		gatewayEntry:   widget.NewEntry(),
+		dnsEntry:       widget.NewEntry(),
+		mtuEntry:       widget.NewEntry(),
+		routesEntry:    widget.NewMultiLineEntry(),
+		vlanEntry:      widget.NewEntry(),
+		rollbackEntry:  widget.NewEntry(),
+	}
+	e.ifaceSelect = widget.NewSelect(nil, e.selectInterface)
+	e.ifaceSelect.PlaceHolder = "请先读取网络配置"
+
+	e.addressesEntry.SetPlaceHolder("每行一个地址，如: 192.168.2.136/24")
+	e.addressesEntry.SetMinRowsVisible(2)
+	e.dnsEntry.SetPlaceHolder("多个用空格分隔，如: 114.114.114.114 8.8.8.8")
+	e.mtuEntry.SetPlaceHolder("为空使用默认值")
+	e.routesEntry.SetPlaceHolder("每行一条，如: 10.0.0.0/8 via 192.168.2.1")
+	e.routesEntry.SetMinRowsVisible(2)
+	e.vlanEntry.SetPlaceHolder("VLAN子接口的父接口，如: eth0")
+	e.rollbackEntry.SetText(strconv.Itoa(defaultRollbackMinutes))
+	return e
+}
+
+// Content 网络配置编辑区域
+func (e *NetworkEditor) Content() fyne.CanvasObject {
+	e.form = widget.NewForm(
+		widget.NewFormItem("", e.autoCheck),
+		widget.NewFormItem("方式", e.methodSelect),
+		widget.NewFormItem("地址", e.addressesEntry),
+		widget.NewFormItem("网关", e.gatewayEntry),
+		widget.NewFormItem("DNS", e.dnsEntry),
+		widget.NewFormItem("MTU", e.mtuEntry),
+		widget.NewFormItem("静态路由", e.routesEntry),
+		widget.NewFormItem("VLAN父接口", e.vlanEntry),
+	)
+	e.form.Hide()
+
+	buttons := container.NewGridWithColumns(3,
+		widget.NewButton("读取网络配置", func() {
+			go e.load()
+		}),
+		widget.NewButton("添加接口", e.addInterface),
+		widget.NewButton("删除接口", e.removeInterface),
+	)
+
+	confirmButton := widget.NewButton("确认网络配置", func() {
+		go func() {
+			if err := e.tool.confirmNetwork(); err != nil {
+				e.tool.AppendOutput("确认网络配置失败: " + err.Error())
+				return
+			}
+			e.tool.AppendOutput("新的网络配置已确认，设备不会自动恢复原配置")
+		}()
+	})
+
+	return container.NewVBox(
+		widget.NewLabel("网络配置(/etc/network/interfaces):"),
+		buttons,
+		e.ifaceSelect,
+		e.form,
+		container.NewBorder(nil, nil, widget.NewLabel("未确认时自动恢复(分钟):"), confirmButton, e.rollbackEntry),
+	)
+}
+
+// load 读取并解析设备上的网络配置
+func (e *NetworkEditor) load() {
+	output, err := e.tool.RunQuietCommand("cat " + interfacesPath)
+	if err != nil {
+		e.tool.AppendOutput("读取网络配置失败: " + err.Error())
+		return
+	}
+
+	file, err := ParseInterfaces(output)
+	if err != nil {
+		e.tool.AppendOutput("解析网络配置失败: " + err.Error())
+		return
+	}
+
+	e.file = file
+	e.original = file.String()
+	e.current = nil
+	e.refreshInterfaces("")
+	e.tool.AppendOutput(fmt.Sprintf("已读取网络配置，共 %d 个接口", len(file.Interfaces())))
+}
+
+// reset 清空已读取的配置，连接其他设备后需重新读取，避免将上一台设备的配置写入
+func (e *NetworkEditor) reset() {
+	e.file = nil
+	e.original = ""
+	e.current = nil
+	e.ifaceSelect.Options = nil
+	e.ifaceSelect.ClearSelected()
+	e.ifaceSelect.PlaceHolder = "请先读取网络配置"
+	if e.form != nil {
+		e.form.Hide()
+	}
+}
+
+// refreshInterfaces 刷新接口列表并选中指定接口，未指定时选中第一个非回环接口
+func (e *NetworkEditor) refreshInterfaces(selected string) {
+	var names []string
+	for _, iface := range e.file.Interfaces() {
+		names = append(names, iface.Name)
+		if selected == "" && iface.Method != "loopback" {
+			selected = iface.Name
+		}
+	}
+	e.ifaceSelect.PlaceHolder = "选择接口"
+	e.ifaceSelect.Options = names
+	if selected == "" {
+		e.ifaceSelect.ClearSelected()
+		e.form.Hide()
+		return
+	}
+	e.ifaceSelect.SetSelected(selected)
+}
+
+func (e *NetworkEditor) selectInterface(name string) {
+	if e.file == nil {
+		return
+	}
+	if e.current != nil && e.current.Name != name {
+		if err := e.apply(); err != nil {
+			dialog.ShowError(fmt.Errorf("接口 %s: %v", e.current.Name, err), e.tool.window)
+		}
+	}
+
+	e.current = e.file.Interface(name)
+	if e.current == nil {
+		e.form.Hide()
+		return
+	}
+	e.autoCheck.SetChecked(e.current.Auto)
+	e.methodSelect.SetSelected(e.current.Method)
+	e.addressesEntry.SetText(strings.Join(e.current.Addresses, "\n"))
+	e.gatewayEntry.SetText(e.current.Gateway)
+	e.dnsEntry.SetText(strings.Join(e.current.DNS, " "))
+	e.mtuEntry.SetText("")
+	if e.current.MTU > 0 {
+		e.mtuEntry.SetText(strconv.Itoa(e.current.MTU))
+	}
+	var routes []string
+	for _, route := range e.current.Routes {
+		routes = append(routes, route.String())
+	}
+	e.routesEntry.SetText(strings.Join(routes, "\n"))
+	e.vlanEntry.SetText(e.current.VlanRawDevice)
+	e.form.Show()
+}
+
+// apply 将输入框中的内容写入当前接口
+func (e *NetworkEditor) apply() error {
+	if e.current == nil {
+		return nil
+	}
+
+	mtu := 0
+	if text := strings.TrimSpace(e.mtuEntry.Text); text != "" {
+		var err error
+		if mtu, err = strconv.Atoi(text); err != nil {
+			return fmt.Errorf("MTU `%s` 不是有效的数字", text)
+		}
+	}
+
+	var routes []NetRoute
+	for _, line := range splitLines(e.routesEntry.Text) {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[1] != "via" {
+			return fmt.Errorf("静态路由 `%s` 格式错误，应为: <网段> via <网关>", line)
+		}
+		routes = append(routes, NetRoute{Destination: fields[0], Gateway: fields[2]})
+	}
+
+	e.current.Auto = e.autoCheck.Checked
+	e.current.Method = e.methodSelect.Selected
+	e.current.Addresses = splitLines(e.addressesEntry.Text)
+	e.current.Gateway = strings.TrimSpace(e.gatewayEntry.Text)
+	e.current.DNS = strings.FieldsFunc(e.dnsEntry.Text, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '，'
+	})
+	e.current.MTU = mtu
+	e.current.Routes = routes
+	e.current.VlanRawDevice = strings.TrimSpace(e.vlanEntry.Text)
+	return nil
+}
+
+func (e *NetworkEditor) addInterface() {
+	if e.file == nil {
+		dialog.ShowInformation("错误", "请先读取网络配置", e.tool.window)
+		return
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("如: eth1、eth0.100")
+	dialog.ShowForm("添加接口", "添加", "取消", []*widget.FormItem{widget.NewFormItem("接口名称", nameEntry)}, func(ok bool) {
+		name := strings.TrimSpace(nameEntry.Text)
+		if !ok || name == "" {
+			return
+		}
+		if _, err := e.file.AddInterface(name); err != nil {
+			dialog.ShowError(err, e.tool.window)
+			return
+		}
+		e.refreshInterfaces(name)
+	}, e.tool.window)
+}
+
+func (e *NetworkEditor) removeInterface() {
+	if e.file == nil || e.current == nil {
+		return
+	}
+
+	name := e.current.Name
+	dialog.ShowConfirm("删除接口", fmt.Sprintf("确定删除接口 %s 的配置吗？", name), func(ok bool) {
+		if !ok {
+			return
+		}
+		e.file.RemoveInterface(name)
+		e.current = nil
+		e.refreshInterfaces("")
+	}, e.tool.window)
+}
+
+// render 生成并校验修改后的网络配置，未读取或未修改时返回false
+// connectedIP为当前连接设备使用的IP，修改后不再是静态地址时返回警告
+func (e *NetworkEditor) render(connectedIP string) (content string, changed bool, warning string, err error) {
+	if e.file == nil {
+		return "", false, "", nil
+	}
+	if err = e.apply(); err != nil {
+		return "", false, "", err
+	}
+
+	content = e.file.String()
+	if content == e.original {
+		return content, false, "", nil
+	}
+
+	if errs := e.file.Validate(); len(errs) > 0 {
+		lines := make([]string, 0, len(errs))
+		for _, err := range errs {
+			lines = append(lines, "· "+err.Error())
+		}
+		return "", false, "", errors.New("网络配置校验失败:\n" + strings.Join(lines, "\n"))
+	}
+
+	// 只在原配置中当前IP为静态地址时判断，DHCP获取的地址无法预知
+	if original, err := ParseInterfaces(e.original); err == nil && original.StaticAddress(connectedIP) && !e.file.StaticAddress(connectedIP) {
+		warning = fmt.Sprintf("更新后设备将不再使用当前连接的IP %s，重启后连接会中断，需使用新的IP重新连接", connectedIP)
+	}
+	return content, true, warning, nil
+}
+
+func splitLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// rollbackMinutes 更新网络配置后等待确认的时间
+func (e *NetworkEditor) rollbackMinutes() (int, error) {
+	minutes, err := strconv.Atoi(strings.TrimSpace(e.rollbackEntry.Text))
+	if err != nil || minutes < minRollbackMinutes {
+		return 0, fmt.Errorf("自动恢复时间 `%s` 无效，至少为 %d 分钟", e.rollbackEntry.Text, minRollbackMinutes)
+	}
+	return minutes, nil
+}
+
+// nextAddress 设备重启后用于重新连接的IP，优先使用当前连接的IP，无法确定时返回空
+func (e *NetworkEditor) nextAddress(connectedIP string) string {
+	if e.file.StaticAddress(connectedIP) {
+		return connectedIP
+	}
+	for _, iface := range e.file.Interfaces() {
+		if !iface.Auto || iface.Method != "static" || len(iface.Addresses) == 0 {
+			continue
+		}
+		if ip, _, err := net.ParseCIDR(iface.Addresses[0]); err == nil && !ip.IsLoopback() {
+			return ip.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"fmt"
+	"time"
+)
+
+const (
+	networkRollbackFile    = interfacesPath + ".rollback" // 待确认时保存的原网络配置
+	networkRollbackService = "emNetRollback"
+	defaultRollbackMinutes = 5
+	minRollbackMinutes     = 2 // 需大于设备重启所需时间
+)
+
+// armNetworkRollback 在设备上保存原网络配置(已有待确认的修改时保留最初的配置)，并安装开机后定时恢复的服务
+// 设备重启后minutes分钟内未确认，将恢复原配置并再次重启
+func armNetworkRollback(runner version.IFlashTool, minutes int) error {
+	service := fmt.Sprintf(`[Unit]
+Description = %s
+
+[Service]
+Type = oneshot
+ExecStart = /bin/sh -c 'if [ -f %s ]; then cp -f %s %s && rm -f %s && systemctl disable %s.timer && reboot; fi'
+`, networkRollbackService, networkRollbackFile, networkRollbackFile, interfacesPath, networkRollbackFile, networkRollbackService)
+
+	timer := fmt.Sprintf(`[Unit]
+Description = %s timer
+
+[Timer]
+OnBootSec = %dmin
+
+[Install]
+WantedBy = timers.target
+`, networkRollbackService, minutes)
+
+	// 上次修改尚未确认时，保留的仍是最初确认过的配置，不能被未确认的配置覆盖
+	if _, err := runner.RunQuietCommand("test -f " + networkRollbackFile); err == nil {
+		runner.AppendOutput("设备上有尚未确认的网络配置，自动恢复时将恢复到最近一次确认的配置")
+	}
+	if _, err := runner.RunAndWaitCommand(fmt.Sprintf("[ -f %s ] || cp -f %s %s", networkRollbackFile, interfacesPath, networkRollbackFile)); err != nil {
+		return err
+	}
+	if err := runner.UploadContent([]byte(service), fmt.Sprintf("/etc/systemd/system/%s.service", networkRollbackService)); err != nil {
+		return err
+	}
+	if err := runner.UploadContent([]byte(timer), fmt.Sprintf("/etc/systemd/system/%s.timer", networkRollbackService)); err != nil {
+		return err
+	}
+	_, err := runner.RunAndWaitCommand(fmt.Sprintf("systemctl daemon-reload && systemctl enable %s.timer", networkRollbackService))
+	return err
+}
+
+// confirmNetwork 确认新的网络配置，取消设备上的自动恢复
+func (t *FirmwareFlashTool) confirmNetwork() error {
+	if _, err := t.RunQuietCommand("test -f " + networkRollbackFile); err != nil {
+		return fmt.Errorf("设备上没有待确认的网络配置")
+	}
+	_, err := t.RunAndWaitCommand(fmt.Sprintf("rm -f %s && systemctl disable --now %s.timer", networkRollbackFile, networkRollbackService))
+	return err
+}
+
+// awaitNetworkConfirm 设备重启后通过新地址重新连接并确认网络配置，超时后设备将自动恢复原配置
+// ip为空时无法预知新地址，需用户手动连接后点击“确认网络配置”
+func (t *FirmwareFlashTool) awaitNetworkConfirm(ip, oldIP string, minutes int) {
+	deadline := time.Now().Add(time.Duration(minutes) * time.Minute)
+	if ip == "" {
+		t.AppendOutput(fmt.Sprintf("新的网络配置中没有静态地址，请在 %s 前连接设备并点击“确认网络配置”，否则设备将恢复原配置", deadline.Format("15:04:05")))
+		return
+	}
+
+	t.AppendOutput(fmt.Sprintf("等待设备重启，将通过 %s 重新连接并确认网络配置，%d 分钟内未确认设备将自动恢复原配置", ip, minutes))
+	// 等待设备关机，避免连上重启前的旧连接
+	waitForShutdown(oldIP, shutdownTimeout)
+
+	if err := t.reconnect(ip, deadline); err != nil {
+		t.AppendOutput(fmt.Sprintf("%d 分钟内未能通过 %s 连接设备，设备将自动恢复原网络配置并重启，请稍后使用 %s 重新连接", minutes, ip, oldIP))
+		return
+	}
+	if err := t.confirmNetwork(); err != nil {
+		t.AppendOutput("确认网络配置失败: " + err.Error())
+		return
+	}
+	t.AppendOutput(fmt.Sprintf("已通过 %s 重新连接设备，新的网络配置已确认", ip))
+}
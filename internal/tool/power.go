@@ -0,0 +1,173 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"fmt"
+	"fyne.io/fyne/v2/dialog"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	shutdownTimeout = time.Minute     // 等待设备关机(SSH无响应)的最长时间
+	rebootTimeout   = 5 * time.Minute // 等待设备重启后SSH恢复的最长时间
+)
+
+// sshReachable 探测设备SSH端口是否可连接
+func sshReachable(ip string) bool {
+	conn, err := net.DialTimeout("tcp", ip+":22", time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// waitForShutdown 等待设备SSH端口无响应，超时返回false
+func waitForShutdown(ip string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !sshReachable(ip) {
+			return true
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return false
+}
+
+// reconnect 等待设备SSH恢复并通过ip重新建立连接，deadline前未连接上返回错误
+func (t *FirmwareFlashTool) reconnect(ip string, deadline time.Time) error {
+	t.ipEntry.SetText(ip)
+	for time.Now().Before(deadline) {
+		// 先探测SSH端口，设备可达后再建立连接
+		if !sshReachable(ip) {
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		if err := t.connToDevice(); err != nil {
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("设备 %s 在 %s 前未恢复SSH连接", ip, deadline.Format("15:04:05"))
+}
+
+// rebootDevice 确认后重启设备，并等待设备重新连接
+func (t *FirmwareFlashTool) rebootDevice() {
+	ip := strings.TrimSpace(t.ipEntry.Text)
+	dialog.ShowConfirm("重启设备", fmt.Sprintf("确定重启设备 %s 吗？", ip), func(ok bool) {
+		if !ok {
+			return
+		}
+		go func() {
+			runner := t.flashTool()
+			start := time.Now()
+			t.AppendOutput("正在重启设备...")
+			// 重启时连接断开也可能返回错误，以SSH是否断开为准
+			_, err := runner.RunAndWaitCommand("reboot")
+			if t.dryRun {
+				return
+			}
+
+			if !waitForShutdown(ip, shutdownTimeout) {
+				if err != nil {
+					t.AppendOutput("重启设备失败: " + err.Error())
+				} else {
+					t.AppendOutput(fmt.Sprintf("重启设备失败: %s 内设备SSH未断开，设备可能未重启", shutdownTimeout))
+				}
+				return
+			}
+
+			t.AppendOutput(fmt.Sprintf("设备已关机，等待设备启动(最长 %s)...", rebootTimeout))
+			if err := t.reconnect(ip, start.Add(rebootTimeout)); err != nil {
+				t.AppendOutput("重启设备失败: " + err.Error() + "，请检查设备电源及网络")
+				return
+			}
+			t.AppendOutput(fmt.Sprintf("设备已重启并重新连接，耗时 %s", time.Since(start).Round(time.Second)))
+		}()
+	}, t.window)
+}
+
+// shutdownDevice 确认后关闭设备，并确认SSH已断开
+func (t *FirmwareFlashTool) shutdownDevice() {
+	ip := strings.TrimSpace(t.ipEntry.Text)
+	message := fmt.Sprintf("确定关闭设备 %s 吗？\n\n关机后需现场重新上电才能启动设备。", ip)
+	dialog.ShowConfirm("关闭设备", message, func(ok bool) {
+		if !ok {
+			return
+		}
+		go func() {
+			t.AppendOutput("正在关闭设备...")
+			_, err := t.flashTool().RunAndWaitCommand("poweroff")
+			if t.dryRun {
+				return
+			}
+
+			if !waitForShutdown(ip, shutdownTimeout) {
+				if err != nil {
+					t.AppendOutput("关闭设备失败: " + err.Error())
+				} else {
+					t.AppendOutput(fmt.Sprintf("关闭设备失败: %s 内设备SSH未断开", shutdownTimeout))
+				}
+				return
+			}
+			t.AppendOutput("设备已关机")
+		}()
+	}, t.window)
+}
+
+// restartAllServices 确认后重启设备上已安装的全部网关服务，并检查重启后的状态
+func (t *FirmwareFlashTool) restartAllServices() {
+	dialog.ShowConfirm("重启全部服务", fmt.Sprintf("确定重启设备上的 %s 服务吗？", strings.Join(version.GatewayServices, "、")), func(ok bool) {
+		if !ok {
+			return
+		}
+		go func() {
+			services, err := version.CollectServices(t, version.GatewayServices)
+			if err != nil {
+				t.AppendOutput("读取服务状态失败: " + err.Error())
+				return
+			}
+			var names []string
+			for _, service := range services {
+				if service.Load != "not-found" {
+					names = append(names, service.Name)
+				}
+			}
+			if len(names) == 0 {
+				t.AppendOutput("设备上未安装网关服务")
+				return
+			}
+
+			if _, err := t.flashTool().RunAndWaitCommand("systemctl restart " + strings.Join(names, " ")); err != nil {
+				t.AppendOutput("重启服务失败: " + err.Error())
+				return
+			}
+			if t.dryRun {
+				return
+			}
+
+			// 等待服务启动后检查状态
+			time.Sleep(3 * time.Second)
+			services, err = version.CollectServices(t, names)
+			if err != nil {
+				t.AppendOutput("读取服务状态失败: " + err.Error())
+				return
+			}
+			failed := 0
+			for _, service := range services {
+				t.AppendOutput(service.String())
+				if service.Active != "active" {
+					failed++
+				}
+			}
+			if failed > 0 {
+				t.AppendOutput(fmt.Sprintf("重启服务后有 %d 个服务未运行，请查看服务日志", failed))
+				return
+			}
+			t.AppendOutput("全部服务已重启")
+		}()
+	}, t.window)
+}
@@ -0,0 +1,268 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"EMInit/pkg/utils"
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"strings"
+)
+
+// SerialEditor v3设备配置中的串口(interfaces)编辑器
+// 设备端串口参数的配置格式尚未确认，只编辑串口及启用状态
+type SerialEditor struct {
+	tool    *FirmwareFlashTool
+	sn      string             // 当前加载配置的SN
+	setting *version.V3Setting // 当前加载的配置
+	current string             // 当前编辑的串口
+
+	portSelect  *widget.Select
+	enableCheck *widget.Check
+	rawLabel    *widget.Label // 非0/1的原始配置
+	appLabel    *widget.Label
+	form        *widget.Form
+}
+
+func NewSerialEditor(t *FirmwareFlashTool) *SerialEditor {
+	e := &SerialEditor{
+		tool:        t,
+		enableCheck: widget.NewCheck("启用", nil),
+		rawLabel:    widget.NewLabel(""),
+		appLabel:    widget.NewLabel(""),
+	}
+	e.portSelect = widget.NewSelect(nil, e.selectPort)
+	e.portSelect.PlaceHolder = "请先加载配置"
+	e.appLabel.Wrapping = fyne.TextWrapWord
+	e.rawLabel.Wrapping = fyne.TextWrapWord
+	return e
+}
+
+// Content 串口配置标签页内容
+func (e *SerialEditor) Content() fyne.CanvasObject {
+	e.form = widget.NewForm(
+		widget.NewFormItem("", e.enableCheck),
+		widget.NewFormItem("原始配置", e.rawLabel),
+	)
+	e.form.Hide()
+
+	buttons := container.NewGridWithColumns(3,
+		widget.NewButton("加载", e.load),
+		widget.NewButton("添加串口", e.addPort),
+		widget.NewButton("删除串口", e.removePort),
+		widget.NewButton("保存", e.save),
+		widget.NewButton("检查设备串口", func() {
+			go e.checkDevices()
+		}),
+	)
+
+	// 串口参数待设备端确认配置格式后再支持编辑
+	notice := widget.NewLabel("波特率、校验位、停止位及RS485模式暂不支持编辑：设备端interfaces中串口参数的字段名尚未确认。")
+	notice.Wrapping = fyne.TextWrapWord
+
+	return container.NewVScroll(container.NewVBox(
+		notice,
+		widget.NewLabel("目标设备SN(v3):"),
+		e.tool.snEntry,
+		buttons,
+		e.appLabel,
+		e.portSelect,
+		e.form,
+	))
+}
+
+// load 从主机加载设备配置中的串口
+func (e *SerialEditor) load() {
+	sn := strings.TrimSpace(e.tool.snEntry.Text)
+	if sn == "" {
+		dialog.ShowInformation("错误", "请输入设备SN", e.tool.window)
+		return
+	}
+
+	data, err := version.ReadSetting("v3", sn)
+	if err != nil {
+		dialog.ShowInformation("错误", fmt.Sprintf("读取配置失败，请先下载初始配置: %v", err), e.tool.window)
+		return
+	}
+	setting, err := version.ParseV3Setting(data)
+	if err != nil {
+		dialog.ShowInformation("错误", err.Error(), e.tool.window)
+		return
+	}
+	if setting.Interfaces == nil {
+		setting.Interfaces = make(map[string]version.SerialPort)
+	}
+
+	e.sn = sn
+	e.setting = setting
+	e.current = ""
+	e.refreshApps()
+	e.refreshPorts("")
+	for _, port := range setting.SerialPortNames() {
+		e.tool.AppendOutput(fmt.Sprintf("串口 %s: %s", port, setting.Interfaces[port]))
+	}
+	e.tool.AppendOutput(fmt.Sprintf("已加载配置: %s, 共 %d 个串口", version.SettingPath("v3", sn), len(setting.Interfaces)))
+}
+
+// refreshApps 显示串口相关应用是否启用
+func (e *SerialEditor) refreshApps() {
+	var apps []string
+	for _, app := range []string{"cgService/serial", "cgProtocol/modbus-rtu"} {
+		apps = append(apps, fmt.Sprintf("%s: %s", app, yesNo(utils.ContainsString(e.setting.App, app))))
+	}
+	e.appLabel.SetText("应用(在“配置编辑”中修改) " + strings.Join(apps, ", "))
+}
+
+// refreshPorts 刷新串口列表并选中指定串口，未指定时选中第一个
+func (e *SerialEditor) refreshPorts(selected string) {
+	ports := e.setting.SerialPortNames()
+	if selected == "" && len(ports) > 0 {
+		selected = ports[0]
+	}
+	e.portSelect.PlaceHolder = "选择串口"
+	e.portSelect.Options = ports
+	if selected == "" {
+		e.portSelect.ClearSelected()
+		e.form.Hide()
+		return
+	}
+	e.portSelect.SetSelected(selected)
+}
+
+func (e *SerialEditor) selectPort(port string) {
+	if e.setting == nil {
+		return
+	}
+	if e.current != "" && e.current != port {
+		if err := e.apply(); err != nil {
+			dialog.ShowError(fmt.Errorf("串口 %s: %v", e.current, err), e.tool.window)
+		}
+	}
+
+	serial, ok := e.setting.Interfaces[port]
+	if !ok {
+		e.current = ""
+		e.form.Hide()
+		return
+	}
+	e.current = port
+	e.enableCheck.SetChecked(serial.Enable == 1)
+	e.rawLabel.SetText("-")
+	if raw := serial.Raw(); raw != "" {
+		e.rawLabel.SetText(raw + "\n(修改启用状态后将按0/1保存)")
+	}
+	e.form.Show()
+}
+
+// apply 将表单中的启用状态写入当前串口
+func (e *SerialEditor) apply() error {
+	if e.setting == nil || e.current == "" {
+		return nil
+	}
+	serial := e.setting.Interfaces[e.current]
+	serial.SetEnable(boolToInt(e.enableCheck.Checked))
+	e.setting.Interfaces[e.current] = serial
+	return nil
+}
+
+func (e *SerialEditor) addPort() {
+	if e.setting == nil {
+		dialog.ShowInformation("错误", "请先加载配置", e.tool.window)
+		return
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("如: /dev/ttyS1、/dev/ttyUSB0")
+	dialog.ShowForm("添加串口", "添加", "取消", []*widget.FormItem{widget.NewFormItem("串口设备", nameEntry)}, func(ok bool) {
+		port := strings.TrimSpace(nameEntry.Text)
+		if !ok || port == "" {
+			return
+		}
+		if _, exists := e.setting.Interfaces[port]; exists {
+			dialog.ShowError(fmt.Errorf("串口 %s 已存在", port), e.tool.window)
+			return
+		}
+		if err := e.apply(); err != nil {
+			dialog.ShowError(fmt.Errorf("串口 %s: %v", e.current, err), e.tool.window)
+			return
+		}
+		e.setting.Interfaces[port] = version.SerialPort{Enable: 1}
+		e.current = ""
+		e.refreshPorts(port)
+	}, e.tool.window)
+}
+
+func (e *SerialEditor) removePort() {
+	if e.setting == nil || e.current == "" {
+		return
+	}
+
+	port := e.current
+	dialog.ShowConfirm("删除串口", fmt.Sprintf("确定删除串口 %s 的配置吗？", port), func(ok bool) {
+		if !ok {
+			return
+		}
+		delete(e.setting.Interfaces, port)
+		e.current = ""
+		e.refreshPorts("")
+	}, e.tool.window)
+}
+
+// save 校验通过后保存配置，已连接设备时检查串口设备是否存在
+func (e *SerialEditor) save() {
+	if e.setting == nil {
+		dialog.ShowInformation("错误", "请先加载配置", e.tool.window)
+		return
+	}
+	if err := e.apply(); err != nil {
+		dialog.ShowError(fmt.Errorf("串口 %s: %v", e.current, err), e.tool.window)
+		return
+	}
+
+	if errs := e.setting.Validate(); len(errs) > 0 {
+		lines := make([]string, 0, len(errs))
+		for _, err := range errs {
+			lines = append(lines, "· "+err.Error())
+		}
+		dialog.ShowError(errors.New("配置校验失败:\n"+strings.Join(lines, "\n")), e.tool.window)
+		return
+	}
+
+	data, err := e.setting.Marshal()
+	if err != nil {
+		dialog.ShowInformation("错误", fmt.Sprintf("序列化配置失败: %v", err), e.tool.window)
+		return
+	}
+	if err := version.WriteEditedSetting("v3", e.sn, data); err != nil {
+		dialog.ShowInformation("错误", fmt.Sprintf("保存配置失败: %v", err), e.tool.window)
+		return
+	}
+	e.tool.AppendOutput(fmt.Sprintf("串口配置已保存: %s", version.SettingPath("v3", e.sn)))
+
+	if e.tool.sshClient != nil {
+		go e.checkDevices()
+	}
+}
+
+// checkDevices 检查配置中的串口在设备上是否存在
+func (e *SerialEditor) checkDevices() {
+	if e.setting == nil {
+		e.tool.AppendOutput("请先加载配置")
+		return
+	}
+
+	ports := e.setting.SerialPortNames()
+	missing, err := version.CheckSerialDevices(e.tool, ports)
+	if err != nil {
+		e.tool.AppendOutput("检查设备串口失败: " + err.Error())
+		return
+	}
+	if len(missing) > 0 {
+		e.tool.AppendOutput(fmt.Sprintf("警告: 设备上不存在串口 %s，请检查配置", strings.Join(missing, "、")))
+		return
+	}
+	e.tool.AppendOutput(fmt.Sprintf("设备上的串口均存在: %s", strings.Join(ports, "、")))
+}
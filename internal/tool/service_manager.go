@@ -0,0 +1,138 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"strconv"
+	"strings"
+)
+
+const defaultJournalLines = 200
+
+var serviceActionNames = map[string]string{
+	"start":   "启动",
+	"stop":    "停止",
+	"restart": "重启",
+	"enable":  "启用开机启动",
+	"disable": "禁用开机启动",
+}
+
+// ServiceManager 网关服务管理，查看状态、启停服务及查看日志
+type ServiceManager struct {
+	tool   *FirmwareFlashTool
+	labels map[string]*widget.Label // 各服务的状态
+}
+
+func NewServiceManager(t *FirmwareFlashTool) *ServiceManager {
+	m := &ServiceManager{
+		tool:   t,
+		labels: make(map[string]*widget.Label),
+	}
+	for _, name := range version.GatewayServices {
+		label := widget.NewLabel("未读取")
+		label.Wrapping = fyne.TextWrapWord
+		m.labels[name] = label
+	}
+	return m
+}
+
+// Content 服务管理区域
+func (m *ServiceManager) Content() fyne.CanvasObject {
+	rows := container.NewVBox()
+	for _, name := range version.GatewayServices {
+		name := name
+		buttons := container.NewHBox()
+		for _, action := range version.ServiceActions {
+			action := action
+			buttons.Add(widget.NewButton(serviceActionNames[action], func() {
+				m.control(name, action)
+			}))
+		}
+		buttons.Add(widget.NewButton("日志", func() {
+			m.showJournal(name)
+		}))
+
+		title := widget.NewLabel(name)
+		title.TextStyle = fyne.TextStyle{Bold: true}
+		rows.Add(container.NewVBox(title, m.labels[name], buttons, widget.NewSeparator()))
+	}
+
+	return container.NewBorder(
+		container.NewHBox(widget.NewButton("刷新服务状态", func() {
+			go m.refresh()
+		})),
+		nil, nil, nil,
+		container.NewVScroll(rows),
+	)
+}
+
+// refresh 读取所有网关服务的状态
+func (m *ServiceManager) refresh() {
+	services, err := version.CollectServices(m.tool, version.GatewayServices)
+	if err != nil {
+		m.tool.AppendOutput("读取服务状态失败: " + err.Error())
+		return
+	}
+	for _, service := range services {
+		if label, ok := m.labels[service.Name]; ok {
+			label.SetText(strings.TrimPrefix(service.String(), service.Name+": "))
+		}
+	}
+}
+
+// control 确认后执行服务操作并刷新状态
+func (m *ServiceManager) control(name, action string) {
+	message := fmt.Sprintf("确定%s服务 %s 吗？", serviceActionNames[action], name)
+	dialog.ShowConfirm("服务管理", message, func(ok bool) {
+		if !ok {
+			return
+		}
+		go func() {
+			m.tool.AppendOutput(fmt.Sprintf("%s服务 %s", serviceActionNames[action], name))
+			if err := version.ControlService(m.tool.flashTool(), name, action); err != nil {
+				m.tool.AppendOutput(fmt.Sprintf("%s服务 %s 失败: %v", serviceActionNames[action], name, err))
+			}
+			m.refresh()
+		}()
+	}, m.tool.window)
+}
+
+// showJournal 显示服务最近的日志
+func (m *ServiceManager) showJournal(name string) {
+	linesEntry := widget.NewEntry()
+	linesEntry.SetText(strconv.Itoa(defaultJournalLines))
+	journal := widget.NewMultiLineEntry()
+	journal.Wrapping = fyne.TextWrapOff
+	journal.TextStyle = fyne.TextStyle{Monospace: true}
+
+	load := func() {
+		lines, err := strconv.Atoi(strings.TrimSpace(linesEntry.Text))
+		if err != nil || lines <= 0 {
+			lines = defaultJournalLines
+		}
+		output, err := version.ServiceJournal(m.tool, name, lines)
+		if err != nil {
+			journal.SetText("读取日志失败: " + err.Error())
+			return
+		}
+		journal.SetText(output)
+		journal.CursorRow = len(strings.Split(output, "\n"))
+		journal.Refresh()
+	}
+
+	content := container.NewBorder(
+		container.NewBorder(nil, nil, widget.NewLabel("最近行数:"), widget.NewButton("刷新", func() {
+			go load()
+		}), linesEntry),
+		nil, nil, nil,
+		journal,
+	)
+	d := dialog.NewCustom(fmt.Sprintf("%s 日志", name), "关闭", content, m.tool.window)
+	d.Resize(fyne.NewSize(900, 600))
+	d.Show()
+	go load()
+}
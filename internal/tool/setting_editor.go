@@ -0,0 +1,387 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"EMInit/pkg/utils"
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"strconv"
+	"strings"
+)
+
+// SettingEditor v3设备配置编辑器
+type SettingEditor struct {
+	tool    *FirmwareFlashTool
+	setting *version.V3Setting // 当前加载的配置
+
+	snEntry         *widget.Entry
+	templateSelect  *widget.Select
+	vpnServerKey    *widget.Entry
+	vpnServerIp     *widget.Entry
+	vpnServerPort   *widget.Entry
+	vpnServerIps    *widget.Entry
+	vpnClientIp     *widget.Entry
+	erpMqttIp       *widget.Entry
+	erpMqttPort     *widget.Entry
+	erpMqttUser     *widget.Entry
+	erpMqttPwd      *widget.Entry
+	erpTls          *widget.Check
+	erpCa           *widget.Entry
+	erpEncrypt      *widget.Check
+	proUrl          *widget.Entry
+	proMqttIp       *widget.Entry
+	proMqttPort     *widget.Entry
+	proMqttUser     *widget.Entry
+	proMqttPwd      *widget.Entry
+	proTls          *widget.Check
+	proCa           *widget.Entry
+	iotDataVar      *widget.Check
+	iotDataEvent    *widget.Check
+	iotDataStat     *widget.Check
+	appGroup        *widget.CheckGroup
+	interfacesEntry *widget.Entry
+}
+
+func NewSettingEditor(t *FirmwareFlashTool) *SettingEditor {
+	e := &SettingEditor{
+		tool:            t,
+		snEntry:         widget.NewEntry(),
+		vpnServerKey:    widget.NewEntry(),
+		vpnServerIp:     widget.NewEntry(),
+		vpnServerPort:   widget.NewEntry(),
+		vpnServerIps:    widget.NewEntry(),
+		vpnClientIp:     widget.NewEntry(),
+		erpMqttIp:       widget.NewEntry(),
+		erpMqttPort:     widget.NewEntry(),
+		erpMqttUser:     widget.NewEntry(),
+		erpMqttPwd:      widget.NewPasswordEntry(),
+		erpTls:          widget.NewCheck("启用TLS", nil),
+		erpCa:           widget.NewMultiLineEntry(),
+		erpEncrypt:      widget.NewCheck("数据加密", nil),
+		proUrl:          widget.NewEntry(),
+		proMqttIp:       widget.NewEntry(),
+		proMqttPort:     widget.NewEntry(),
+		proMqttUser:     widget.NewEntry(),
+		proMqttPwd:      widget.NewPasswordEntry(),
+		proTls:          widget.NewCheck("启用TLS", nil),
+		proCa:           widget.NewMultiLineEntry(),
+		iotDataVar:      widget.NewCheck("变量数据", nil),
+		iotDataEvent:    widget.NewCheck("事件数据", nil),
+		iotDataStat:     widget.NewCheck("统计数据", nil),
+		appGroup:        widget.NewCheckGroup(append([]string{}, version.KnownApps...), nil),
+		interfacesEntry: widget.NewMultiLineEntry(),
+	}
+
+	e.snEntry.SetPlaceHolder("请输入设备SN")
+	e.interfacesEntry.SetPlaceHolder("每行一个串口，如: /dev/ttyS0=1")
+	return e
+}
+
+// Content 配置编辑标签页内容
+func (e *SettingEditor) Content() fyne.CanvasObject {
+	form := widget.NewForm(
+		widget.NewFormItem("VPN", widget.NewLabel("")),
+		widget.NewFormItem("server_key", e.vpnServerKey),
+		widget.NewFormItem("server_ip", e.vpnServerIp),
+		widget.NewFormItem("server_port", e.vpnServerPort),
+		widget.NewFormItem("server_ips", e.vpnServerIps),
+		widget.NewFormItem("client_ip", e.vpnClientIp),
+		widget.NewFormItem("ERP平台", widget.NewLabel("")),
+		widget.NewFormItem("mqtt_ip", e.erpMqttIp),
+		widget.NewFormItem("mqtt_port", e.erpMqttPort),
+		widget.NewFormItem("mqtt_user", e.erpMqttUser),
+		widget.NewFormItem("mqtt_pwd", e.erpMqttPwd),
+		widget.NewFormItem("tls", e.erpTls),
+		widget.NewFormItem("ca", e.erpCa),
+		widget.NewFormItem("encrypt", e.erpEncrypt),
+		widget.NewFormItem("项目平台", widget.NewLabel("")),
+		widget.NewFormItem("url", e.proUrl),
+		widget.NewFormItem("mqtt_ip", e.proMqttIp),
+		widget.NewFormItem("mqtt_port", e.proMqttPort),
+		widget.NewFormItem("mqtt_user", e.proMqttUser),
+		widget.NewFormItem("mqtt_pwd", e.proMqttPwd),
+		widget.NewFormItem("tls", e.proTls),
+		widget.NewFormItem("ca", e.proCa),
+		widget.NewFormItem("数据上报", container.NewHBox(e.iotDataVar, e.iotDataEvent, e.iotDataStat)),
+		widget.NewFormItem("应用", e.appGroup),
+		widget.NewFormItem("串口", e.interfacesEntry),
+	)
+
+	buttons := container.NewGridWithColumns(3,
+		widget.NewButton("加载", e.load),
+		widget.NewButton("校验", func() {
+			if _, errs := e.validate(); len(errs) > 0 {
+				e.showErrors(errs)
+				return
+			}
+			dialog.ShowInformation("校验", "配置校验通过", e.tool.window)
+		}),
+		widget.NewButton("保存", e.save),
+	)
+
+	templates, err := version.ListSettingTemplates()
+	if err != nil {
+		e.tool.AppendOutput(fmt.Sprintf("读取配置模板失败: %v", err))
+	}
+	e.templateSelect = widget.NewSelect(templates, nil)
+	e.templateSelect.PlaceHolder = "选择配置模板"
+
+	top := container.NewVBox(
+		widget.NewLabel("目标设备SN(v3):"),
+		e.snEntry,
+		buttons,
+		container.NewBorder(nil, nil, nil, widget.NewButton("从模板创建", e.createFromTemplate), e.templateSelect),
+	)
+
+	return container.NewBorder(top, nil, nil, nil, container.NewVScroll(form))
+}
+
+// load 从主机加载设备配置
+func (e *SettingEditor) load() {
+	sn := strings.TrimSpace(e.snEntry.Text)
+	if sn == "" {
+		dialog.ShowInformation("错误", "请输入设备SN", e.tool.window)
+		return
+	}
+
+	data, err := version.ReadSetting("v3", sn)
+	if err != nil {
+		dialog.ShowInformation("错误", fmt.Sprintf("读取配置失败，请先下载初始配置: %v", err), e.tool.window)
+		return
+	}
+
+	setting, err := version.ParseV3Setting(data)
+	if err != nil {
+		dialog.ShowInformation("错误", err.Error(), e.tool.window)
+		return
+	}
+
+	e.setting = setting
+	e.fill(setting)
+	e.tool.AppendOutput(fmt.Sprintf("已加载配置: %s", version.SettingPath("v3", sn)))
+}
+
+// createFromTemplate 使用模板为设备生成配置，已存在时确认是否覆盖
+func (e *SettingEditor) createFromTemplate() {
+	sn := strings.TrimSpace(e.snEntry.Text)
+	if sn == "" {
+		dialog.ShowInformation("错误", "请输入设备SN", e.tool.window)
+		return
+	}
+	name := e.templateSelect.Selected
+	if name == "" {
+		dialog.ShowInformation("错误", "请选择配置模板", e.tool.window)
+		return
+	}
+
+	create := func() {
+		setting, err := version.CreateSettingFromTemplate(name, sn, true)
+		if err != nil {
+			dialog.ShowInformation("错误", err.Error(), e.tool.window)
+			return
+		}
+
+		e.setting = setting
+		e.fill(setting)
+		e.tool.AppendOutput(fmt.Sprintf("已使用模板 %s 生成配置: %s", name, version.SettingPath("v3", sn)))
+		for _, err := range setting.Validate() {
+			e.tool.AppendOutput("配置待完善: " + err.Error())
+		}
+	}
+
+	if !version.SettingExists("v3", sn) {
+		create()
+		return
+	}
+	dialog.ShowCustomConfirm("确认覆盖", "是", "否", widget.NewLabel(fmt.Sprintf("设备 %s 的配置已存在，确定使用模板覆盖吗？", sn)), func(b bool) {
+		if b {
+			create()
+		}
+	}, e.tool.window)
+}
+
+// save 校验通过后保存设备配置
+func (e *SettingEditor) save() {
+	sn := strings.TrimSpace(e.snEntry.Text)
+	if sn == "" {
+		dialog.ShowInformation("错误", "请输入设备SN", e.tool.window)
+		return
+	}
+
+	setting, errs := e.validate()
+	if len(errs) > 0 {
+		e.showErrors(errs)
+		return
+	}
+
+	data, err := setting.Marshal()
+	if err != nil {
+		dialog.ShowInformation("错误", fmt.Sprintf("序列化配置失败: %v", err), e.tool.window)
+		return
+	}
+
+	if err := version.WriteEditedSetting("v3", sn, data); err != nil {
+		dialog.ShowInformation("错误", fmt.Sprintf("保存配置失败: %v", err), e.tool.window)
+		return
+	}
+
+	e.setting = setting
+	e.tool.AppendOutput(fmt.Sprintf("配置已保存: %s", version.SettingPath("v3", sn)))
+	dialog.ShowInformation("保存", "配置已保存", e.tool.window)
+}
+
+// fill 将配置内容填充到表单
+func (e *SettingEditor) fill(s *version.V3Setting) {
+	e.vpnServerKey.SetText(s.Vpn.ServerKey)
+	e.vpnServerIp.SetText(s.Vpn.ServerIp)
+	e.vpnServerPort.SetText(portText(s.Vpn.ServerPort))
+	e.vpnServerIps.SetText(s.Vpn.ServerIps)
+	e.vpnClientIp.SetText(s.Vpn.ClientIp)
+
+	e.erpMqttIp.SetText(s.Erp.MqttIp)
+	e.erpMqttPort.SetText(portText(s.Erp.MqttPort))
+	e.erpMqttUser.SetText(s.Erp.MqttUser)
+	e.erpMqttPwd.SetText(s.Erp.MqttPwd)
+	e.erpTls.SetChecked(s.Erp.Tls == 1)
+	e.erpCa.SetText(s.Erp.Ca)
+	e.erpEncrypt.SetChecked(s.Erp.Encrypt == 1)
+
+	e.proUrl.SetText(s.Pro.Url)
+	e.proMqttIp.SetText(s.Pro.MqttIp)
+	e.proMqttPort.SetText(portText(s.Pro.MqttPort))
+	e.proMqttUser.SetText(s.Pro.MqttUser)
+	e.proMqttPwd.SetText(s.Pro.MqttPwd)
+	e.proTls.SetChecked(s.Pro.Tls == 1)
+	e.proCa.SetText(s.Pro.Ca)
+
+	e.iotDataVar.SetChecked(s.Iot.DataVar == 1)
+	e.iotDataEvent.SetChecked(s.Iot.DataEvent == 1)
+	e.iotDataStat.SetChecked(s.Iot.DataStat == 1)
+
+	// 配置中存在未知应用时也显示出来，校验时提示
+	options := append([]string{}, version.KnownApps...)
+	for _, app := range s.App {
+		if !utils.ContainsString(options, app) {
+			options = append(options, app)
+		}
+	}
+	e.appGroup.Options = options
+	e.appGroup.SetSelected(s.App)
+
+	ports := s.SerialPortNames()
+	lines := make([]string, 0, len(ports))
+	for _, port := range ports {
+		lines = append(lines, fmt.Sprintf("%s=%d", port, s.Interfaces[port].Enable))
+	}
+	e.interfacesEntry.SetText(strings.Join(lines, "\n"))
+}
+
+// validate 读取表单内容并校验
+func (e *SettingEditor) validate() (*version.V3Setting, []error) {
+	setting := &version.V3Setting{}
+	if e.setting != nil {
+		// 复制一份，保留原始配置中未知的字段
+		*setting = *e.setting
+	}
+
+	var errs []error
+	parsePort := func(name, text string) int {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return 0
+		}
+		port, err := strconv.Atoi(text)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s `%s` 不是有效的数字", name, text))
+		}
+		return port
+	}
+
+	setting.Vpn.ServerKey = strings.TrimSpace(e.vpnServerKey.Text)
+	setting.Vpn.ServerIp = strings.TrimSpace(e.vpnServerIp.Text)
+	setting.Vpn.ServerPort = parsePort("vpn.server_port", e.vpnServerPort.Text)
+	setting.Vpn.ServerIps = strings.TrimSpace(e.vpnServerIps.Text)
+	setting.Vpn.ClientIp = strings.TrimSpace(e.vpnClientIp.Text)
+
+	setting.Erp.MqttIp = strings.TrimSpace(e.erpMqttIp.Text)
+	setting.Erp.MqttPort = parsePort("erp.mqtt_port", e.erpMqttPort.Text)
+	setting.Erp.MqttUser = strings.TrimSpace(e.erpMqttUser.Text)
+	setting.Erp.MqttPwd = e.erpMqttPwd.Text
+	setting.Erp.Tls = boolToInt(e.erpTls.Checked)
+	setting.Erp.Ca = e.erpCa.Text
+	setting.Erp.Encrypt = boolToInt(e.erpEncrypt.Checked)
+
+	setting.Pro.Url = strings.TrimSpace(e.proUrl.Text)
+	setting.Pro.MqttIp = strings.TrimSpace(e.proMqttIp.Text)
+	setting.Pro.MqttPort = parsePort("pro.mqtt_port", e.proMqttPort.Text)
+	setting.Pro.MqttUser = strings.TrimSpace(e.proMqttUser.Text)
+	setting.Pro.MqttPwd = e.proMqttPwd.Text
+	setting.Pro.Tls = boolToInt(e.proTls.Checked)
+	setting.Pro.Ca = e.proCa.Text
+
+	setting.Iot.DataVar = boolToInt(e.iotDataVar.Checked)
+	setting.Iot.DataEvent = boolToInt(e.iotDataEvent.Checked)
+	setting.Iot.DataStat = boolToInt(e.iotDataStat.Checked)
+
+	// 按选项顺序保存应用列表
+	setting.App = nil
+	for _, app := range e.appGroup.Options {
+		if utils.ContainsString(e.appGroup.Selected, app) {
+			setting.App = append(setting.App, app)
+		}
+	}
+
+	setting.Interfaces = make(map[string]version.SerialPort)
+	for _, line := range strings.Split(e.interfacesEntry.Text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			errs = append(errs, fmt.Errorf("串口配置 `%s` 格式错误，应为: /dev/ttyS0=1", line))
+			continue
+		}
+		enable, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("串口配置 `%s` 的值不是有效的数字", line))
+			continue
+		}
+		port := strings.TrimSpace(parts[0])
+		serial := version.SerialPort{}
+		if e.setting != nil {
+			serial = e.setting.Interfaces[port]
+		}
+		serial.SetEnable(enable)
+		setting.Interfaces[port] = serial
+	}
+
+	errs = append(errs, setting.Validate()...)
+	return setting, errs
+}
+
+func (e *SettingEditor) showErrors(errs []error) {
+	lines := make([]string, 0, len(errs))
+	for _, err := range errs {
+		lines = append(lines, "· "+err.Error())
+	}
+	dialog.ShowError(errors.New("配置校验失败:\n"+strings.Join(lines, "\n")), e.tool.window)
+}
+
+func portText(port int) string {
+	if port == 0 {
+		return ""
+	}
+	return strconv.Itoa(port)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,109 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"strconv"
+	"strings"
+)
+
+// showSettingPolicyDialog 设置刷写前检查初始配置的有效期及是否禁止刷写
+func (t *FirmwareFlashTool) showSettingPolicyDialog() {
+	policy := version.LoadSettingPolicy()
+
+	maxAgeEntry := widget.NewEntry()
+	maxAgeEntry.SetText(strconv.Itoa(policy.MaxAgeDays))
+	blockCheck := widget.NewCheck("配置缺失或过期时禁止刷写", nil)
+	blockCheck.SetChecked(policy.Block)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("配置有效期(天，0为不检查)", maxAgeEntry),
+		widget.NewFormItem("", blockCheck),
+	}
+	dialog.ShowForm("配置有效期", "保存", "取消", items, func(ok bool) {
+		if !ok {
+			return
+		}
+
+		days, err := strconv.Atoi(strings.TrimSpace(maxAgeEntry.Text))
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("配置有效期 `%s` 不是有效的天数", maxAgeEntry.Text), t.window)
+			return
+		}
+		policy = version.SettingPolicy{MaxAgeDays: days, Block: blockCheck.Checked}
+		if err := version.SaveSettingPolicy(policy); err != nil {
+			dialog.ShowError(err, t.window)
+			return
+		}
+		action := "仅提示"
+		if policy.Block {
+			action = "禁止刷写"
+		}
+		t.AppendOutput(fmt.Sprintf("配置有效期已设置为 %d 天, 缺失或过期时%s", policy.MaxAgeDays, action))
+	}, t.window)
+}
+
+// checkSettingFreshness 刷写前检查主机上的初始配置，缺失或过期时提示重新下载，检查通过或用户确认后执行proceed
+func (t *FirmwareFlashTool) checkSettingFreshness(v version.IFirmwareVersion, ver, sn string, proceed func()) {
+	policy := version.LoadSettingPolicy()
+	freshness, err := version.CheckSettingFreshness(ver, sn, policy)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("检查初始配置失败: %v", err), t.window)
+		return
+	}
+	if freshness.Status == version.SettingFresh {
+		proceed()
+		return
+	}
+
+	message := fmt.Sprintf("设备 %s: %s。", sn, freshness)
+	if freshness.Status == version.SettingStale {
+		message += fmt.Sprintf("\n已超过有效期 %d 天，ERP平台上的配置可能已更新。", policy.MaxAgeDays)
+	}
+	blocked := freshness.Blocked(policy)
+	if blocked {
+		message += "\n当前设置为禁止刷写，请先重新下载初始配置。"
+	}
+	label := widget.NewLabel(message)
+	label.Wrapping = fyne.TextWrapWord
+
+	d := dialog.NewCustomWithoutButtons("初始配置检查", label, t.window)
+	downloadButton := widget.NewButton("重新下载", func() {
+		d.Hide()
+		t.AppendOutput(fmt.Sprintf("重新下载设备 %s 的初始配置...", sn))
+		// 下载可能较慢，不阻塞界面
+		go func() {
+			if err := v.DownloadConfig(sn); err != nil {
+				// 无法连接ERP平台时回到检查，由用户决定是否继续
+				t.AppendOutput(fmt.Sprintf("下载配置失败! 设备SN: %s, 错误信息: %s", sn, err.Error()))
+				t.warnUnregistered(sn, err)
+				dialog.ShowConfirm("下载失败", fmt.Sprintf("下载初始配置失败: %v\n是否返回重新选择？", err), func(retry bool) {
+					if retry {
+						t.checkSettingFreshness(v, ver, sn, proceed)
+					}
+				}, t.window)
+				return
+			}
+			t.AppendOutput(fmt.Sprintf("下载配置成功! 设备SN: %s", sn))
+			proceed()
+		}()
+	})
+	downloadButton.Importance = widget.HighImportance
+	continueButton := widget.NewButton("继续刷写", func() {
+		d.Hide()
+		t.AppendOutput(fmt.Sprintf("设备 %s 的初始配置%s，继续刷写", sn, freshness.Status))
+		proceed()
+	})
+	if blocked {
+		continueButton.Disable()
+	}
+	cancelButton := widget.NewButton("取消", func() {
+		d.Hide()
+	})
+
+	d.SetButtons([]fyne.CanvasObject{cancelButton, continueButton, downloadButton})
+	d.Show()
+}
@@ -0,0 +1,67 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2/dialog"
+	"strings"
+)
+
+// checkDeviceSN 连接设备后核对设备上的SN，输入为空时填入设备SN
+func (t *FirmwareFlashTool) checkDeviceSN() {
+	check := version.CheckSN(t, t.snEntry.Text)
+	sn := check.DeviceSN()
+	if sn == "" {
+		t.AppendOutput("设备上没有记录SN，可能是未刷写过的新设备")
+		return
+	}
+	t.AppendOutput(fmt.Sprintf("设备SN: %s", sn))
+	if check.Input == "" {
+		t.snEntry.SetText(sn)
+		t.AppendOutput("已将设备SN填入目标设备SN")
+	}
+
+	if mismatches := check.Mismatches(); len(mismatches) > 0 {
+		for _, mismatch := range mismatches {
+			t.AppendOutput("警告: " + mismatch)
+		}
+		dialog.ShowInformation("SN核对", strings.Join(mismatches, "\n")+"\n\n请核对设备标签上的SN。", t.window)
+	}
+}
+
+// confirmSN 刷写前核对输入的SN与设备上的SN及主机上的配置，有问题时由用户确认后执行proceed
+func (t *FirmwareFlashTool) confirmSN(sn string, proceed func()) {
+	var runner version.IFlashTool
+	if t.sshClient != nil {
+		runner = t
+	}
+	mismatches := version.CheckSN(runner, sn).Mismatches()
+	if len(mismatches) == 0 {
+		proceed()
+		return
+	}
+
+	for _, mismatch := range mismatches {
+		t.AppendOutput("警告: " + mismatch)
+	}
+	message := strings.Join(mismatches, "\n") + "\n\nSN错误会将错误的SN写入cgManager.yaml和frpc代理名称，确定继续吗？"
+	conf := dialog.NewConfirm("SN核对", message, func(ok bool) {
+		if !ok {
+			t.AppendOutput("SN核对未通过，已取消操作")
+			return
+		}
+		t.AppendOutput(fmt.Sprintf("已确认使用SN %s 继续", sn))
+		proceed()
+	}, t.window)
+	conf.SetConfirmText("继续")
+	conf.SetDismissText("取消")
+	conf.Show()
+}
+
+// warnUnregistered ERP平台报告SN未注册时提示SN可能输入有误
+func (t *FirmwareFlashTool) warnUnregistered(sn string, err error) {
+	if errors.Is(err, version.ErrNotRegistered) {
+		t.AppendOutput(fmt.Sprintf("警告: ERP平台上没有注册SN `%s`，请核对SN是否输入有误", sn))
+	}
+}
@@ -0,0 +1,141 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"context"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"strings"
+	"time"
+)
+
+const statusPollInterval = 10 * time.Second // 设备状态刷新间隔
+
+// StatusDashboard 设备运行状态面板，连接设备后定时刷新
+type StatusDashboard struct {
+	tool *FirmwareFlashTool
+
+	healthLabel  *widget.Label
+	uptimeLabel  *widget.Label
+	loadLabel    *widget.Label
+	memoryLabel  *widget.Label
+	diskLabel    *widget.Label
+	tempLabel    *widget.Label
+	osLabel      *widget.Label
+	kernelLabel  *widget.Label
+	addressLabel *widget.Label
+	serviceLabel *widget.Label
+	updatedLabel *widget.Label
+}
+
+func NewStatusDashboard(t *FirmwareFlashTool) *StatusDashboard {
+	d := &StatusDashboard{
+		tool:         t,
+		healthLabel:  widget.NewLabel(""),
+		uptimeLabel:  widget.NewLabel(""),
+		loadLabel:    widget.NewLabel(""),
+		memoryLabel:  widget.NewLabel(""),
+		diskLabel:    widget.NewLabel(""),
+		tempLabel:    widget.NewLabel(""),
+		osLabel:      widget.NewLabel(""),
+		kernelLabel:  widget.NewLabel(""),
+		addressLabel: widget.NewLabel(""),
+		serviceLabel: widget.NewLabel(""),
+		updatedLabel: widget.NewLabel(""),
+	}
+	d.healthLabel.Wrapping = fyne.TextWrapWord
+	d.healthLabel.TextStyle = fyne.TextStyle{Bold: true}
+	d.clear("未连接")
+	return d
+}
+
+// Content 设备状态面板内容
+func (d *StatusDashboard) Content() fyne.CanvasObject {
+	form := widget.NewForm(
+		widget.NewFormItem("健康状态", d.healthLabel),
+		widget.NewFormItem("运行时间", d.uptimeLabel),
+		widget.NewFormItem("平均负载", d.loadLabel),
+		widget.NewFormItem("内存", d.memoryLabel),
+		widget.NewFormItem("/datas", d.diskLabel),
+		widget.NewFormItem("CPU温度", d.tempLabel),
+		widget.NewFormItem("系统", d.osLabel),
+		widget.NewFormItem("内核", d.kernelLabel),
+		widget.NewFormItem("网口", d.addressLabel),
+		widget.NewFormItem("服务", d.serviceLabel),
+		widget.NewFormItem("更新时间", d.updatedLabel),
+	)
+
+	return container.NewBorder(
+		container.NewHBox(widget.NewButton("立即刷新", func() {
+			go d.refresh()
+		})),
+		nil, nil, nil,
+		container.NewVScroll(form),
+	)
+}
+
+// poll 定时刷新设备状态，连接断开(ctx取消)后停止
+func (d *StatusDashboard) poll(ctx context.Context) {
+	d.refresh()
+
+	ticker := time.NewTicker(statusPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			d.clear("未连接")
+			return
+		case <-ticker.C:
+			d.refresh()
+		}
+	}
+}
+
+func (d *StatusDashboard) refresh() {
+	status, err := version.CollectStatus(d.tool)
+	if err != nil {
+		if d.tool.sshClient == nil {
+			d.clear("未连接")
+			return
+		}
+		d.clear("读取失败: " + err.Error())
+		return
+	}
+
+	if problems := status.Problems(); len(problems) > 0 {
+		d.healthLabel.SetText("异常: " + strings.Join(problems, "; "))
+	} else {
+		d.healthLabel.SetText("正常")
+	}
+	d.uptimeLabel.SetText(status.UptimeText())
+	d.loadLabel.SetText(status.Load)
+	d.memoryLabel.SetText(status.Memory())
+	d.diskLabel.SetText(status.Disk())
+	d.tempLabel.SetText(status.Temperature())
+	d.osLabel.SetText(status.OS)
+	d.kernelLabel.SetText(status.Kernel)
+
+	var addresses []string
+	for _, addr := range status.Addresses {
+		addresses = append(addresses, fmt.Sprintf("%s: %s", addr.Interface, addr.Address))
+	}
+	d.addressLabel.SetText(strings.Join(addresses, "\n"))
+
+	var services []string
+	for _, service := range status.Services {
+		services = append(services, fmt.Sprintf("%s: %s(%s)", service.Name, service.Active, service.Sub))
+	}
+	d.serviceLabel.SetText(strings.Join(services, "\n"))
+	d.updatedLabel.SetText(status.CollectedAt.Format("2006-01-02 15:04:05"))
+}
+
+// clear 清空面板，显示连接状态
+func (d *StatusDashboard) clear(health string) {
+	d.healthLabel.SetText(health)
+	for _, label := range []*widget.Label{d.uptimeLabel, d.loadLabel, d.memoryLabel, d.diskLabel, d.tempLabel,
+		d.osLabel, d.kernelLabel, d.addressLabel, d.serviceLabel, d.updatedLabel} {
+		label.SetText("-")
+	}
+}
@@ -0,0 +1,265 @@
+package tool
+
+import (
+	"EMInit/internal/version"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"strings"
+	"time"
+)
+
+const (
+	timesyncdConfig = "/etc/systemd/timesyncd.conf.d/eminit.conf" // systemd-timesyncd 的NTP服务器配置
+	chronySources   = "/etc/chrony/sources.d/eminit.sources"      // chrony 的NTP服务器配置
+	timezoneMarker  = "/etc/eminit_timezone"                      // 工具设置过的时区，v3_install.sh 检测到时不再修改时区
+)
+
+// 推荐的NTP服务器，只作为输入提示
+var defaultNTPServers = []string{"ntp.aliyun.com", "ntp.tencent.com", "cn.pool.ntp.org"}
+
+// 常用时区，读取设备状态后替换为设备支持的时区列表
+var commonTimezones = []string{
+	"Asia/Shanghai", "Asia/Hong_Kong", "Asia/Taipei", "Asia/Singapore", "Asia/Tokyo", "Asia/Seoul",
+	"Asia/Bangkok", "Asia/Jakarta", "Asia/Kolkata", "Asia/Dubai", "Europe/London", "Europe/Berlin",
+	"Europe/Moscow", "America/New_York", "America/Chicago", "America/Los_Angeles", "Australia/Sydney", "UTC",
+}
+
+// TimeStatus 设备当前的时间、时区及同步状态
+type TimeStatus struct {
+	Time         string
+	Timezone     string
+	NTP          bool   // 是否启用了网络时间同步
+	Synchronized bool   // 是否已与NTP服务器同步
+	Service      string // 时间同步服务: chrony、systemd-timesyncd，未安装为空
+}
+
+func (s *TimeStatus) String() string {
+	service := s.Service
+	if service == "" {
+		service = "未安装"
+	}
+	return fmt.Sprintf("设备时间: %s, 时区: %s, 同步服务: %s, NTP: %s, 已同步: %s",
+		s.Time, s.Timezone, service, yesNo(s.NTP), yesNo(s.Synchronized))
+}
+
+// TimeSettings 设备时区及NTP设置
+type TimeSettings struct {
+	tool    *FirmwareFlashTool
+	status  *TimeStatus // 最近一次读取的设备状态，未读取时为nil
+	servers []string    // 最近一次读取的设备NTP服务器
+
+	timezoneSelect *widget.Select
+	ntpEntry       *widget.Entry
+	statusLabel    *widget.Label
+}
+
+func NewTimeSettings(t *FirmwareFlashTool) *TimeSettings {
+	s := &TimeSettings{
+		tool:           t,
+		timezoneSelect: widget.NewSelect(commonTimezones, nil),
+		ntpEntry:       widget.NewEntry(),
+		statusLabel:    widget.NewLabel("设备时间: 未读取"),
+	}
+	s.timezoneSelect.PlaceHolder = "请先读取时间状态"
+	s.ntpEntry.SetPlaceHolder("多个用空格分隔，如: " + strings.Join(defaultNTPServers, " "))
+	s.statusLabel.Wrapping = fyne.TextWrapWord
+	return s
+}
+
+// Content 时间设置区域
+func (s *TimeSettings) Content() fyne.CanvasObject {
+	return container.NewVBox(
+		widget.NewForm(
+			widget.NewFormItem("时区", s.timezoneSelect),
+			widget.NewFormItem("NTP服务器", s.ntpEntry),
+		),
+		container.NewBorder(nil, nil, nil, widget.NewButton("读取时间状态", func() {
+			go s.load()
+		}), s.statusLabel),
+	)
+}
+
+// reset 清空读取的时间状态，连接其他设备后需重新读取
+func (s *TimeSettings) reset() {
+	s.status = nil
+	s.servers = nil
+	s.timezoneSelect.ClearSelected()
+	s.ntpEntry.SetText("")
+	s.statusLabel.SetText("设备时间: 未读取")
+}
+
+// load 读取设备的时间状态及支持的时区
+func (s *TimeSettings) load() {
+	status, err := readTimeStatus(s.tool)
+	if err != nil {
+		s.tool.AppendOutput("读取时间状态失败: " + err.Error())
+		return
+	}
+	s.statusLabel.SetText(status.String())
+	s.tool.AppendOutput(status.String())
+
+	if output, err := s.tool.RunQuietCommand("timedatectl list-timezones"); err == nil {
+		if zones := strings.Fields(output); len(zones) > 0 {
+			s.timezoneSelect.Options = zones
+		}
+	}
+	if status.Timezone != "" {
+		s.timezoneSelect.SetSelected(status.Timezone)
+	}
+
+	servers := readNTPServers(s.tool, status.Service)
+	s.ntpEntry.SetText(strings.Join(servers, " "))
+	s.status = status
+	s.servers = servers
+}
+
+// readNTPServers 读取工具写入的NTP服务器配置，没有配置时返回空
+func readNTPServers(runner version.IFlashTool, service string) []string {
+	var servers []string
+	switch service {
+	case "chrony":
+		if output, err := runner.RunQuietCommand("cat " + chronySources); err == nil {
+			for _, line := range strings.Split(output, "\n") {
+				if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "server" {
+					servers = append(servers, fields[1])
+				}
+			}
+		}
+	case "systemd-timesyncd":
+		if output, err := runner.RunQuietCommand("cat " + timesyncdConfig); err == nil {
+			for _, line := range strings.Split(output, "\n") {
+				if value, ok := strings.CutPrefix(strings.TrimSpace(line), "NTP="); ok {
+					servers = append(servers, strings.Fields(value)...)
+				}
+			}
+		}
+	}
+	return servers
+}
+
+// readTimeStatus 通过 timedatectl 读取设备时间状态，不支持时只读取时间
+func readTimeStatus(runner version.IFlashTool) (*TimeStatus, error) {
+	status := &TimeStatus{}
+	output, err := runner.RunQuietCommand("timedatectl show")
+	if err == nil {
+		for _, line := range strings.Split(output, "\n") {
+			key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Timezone":
+				status.Timezone = value
+			case "NTP":
+				status.NTP = value == "yes"
+			case "NTPSynchronized":
+				status.Synchronized = value == "yes"
+			}
+		}
+	}
+
+	output, err = runner.RunQuietCommand("date '+%Y-%m-%d %H:%M:%S %Z'")
+	if err != nil {
+		return nil, err
+	}
+	status.Time = strings.TrimSpace(output)
+
+	if _, err := runner.RunQuietCommand("systemctl is-active chrony || systemctl is-active chronyd"); err == nil {
+		status.Service = "chrony"
+	} else if _, err := runner.RunQuietCommand("systemctl cat systemd-timesyncd"); err == nil {
+		status.Service = "systemd-timesyncd"
+	}
+	return status, nil
+}
+
+// apply 只修改与读取时不同的时区和NTP服务器，未读取时间状态时不修改；设备未与NTP服务器同步时，按需使用主机时间
+func (s *TimeSettings) apply(runner version.IFlashTool, syncHostTime bool) error {
+	status, err := readTimeStatus(runner)
+	if err != nil {
+		return err
+	}
+
+	servers := strings.Fields(s.ntpEntry.Text)
+	if s.status == nil {
+		s.tool.AppendOutput("未读取时间状态，不修改时区及NTP服务器")
+	} else {
+		if timezone := s.timezoneSelect.Selected; timezone != "" {
+			if timezone != s.status.Timezone && timezone != status.Timezone {
+				s.tool.AppendOutput(fmt.Sprintf("设置时区: %s", timezone))
+				if _, err := runner.RunAndWaitCommand("timedatectl set-timezone " + timezone); err != nil {
+					return err
+				}
+			}
+			// 记录已确认的时区(包括UTC)，之后刷写时安装脚本不再改为默认时区
+			if err := runner.UploadContent([]byte(timezone+"\n"), timezoneMarker); err != nil {
+				return err
+			}
+		}
+
+		if len(servers) > 0 && strings.Join(servers, " ") != strings.Join(s.servers, " ") {
+			s.tool.AppendOutput(fmt.Sprintf("设置NTP服务器: %s", strings.Join(servers, " ")))
+			if err := configureNTP(runner, status.Service, servers); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !syncHostTime {
+		return nil
+	}
+	// 设备已与NTP服务器同步时以网络时间为准，能ping通服务器不代表NTP可用
+	if status.NTP && status.Synchronized {
+		s.tool.AppendOutput("设备已与NTP服务器同步，使用网络时间，不同步主机时间")
+		return nil
+	}
+
+	// 以UTC设置时间，避免主机与设备时区不同
+	s.tool.AppendOutput("设备未与NTP服务器同步，正在同步当前主机时间...")
+	if _, err := runner.RunAndWaitCommand(fmt.Sprintf("date -u -s \"%s\"", time.Now().UTC().Format("2006-01-02 15:04:05"))); err != nil {
+		return err
+	}
+	// 写入硬件时钟
+	_, err = runner.RunAndWaitCommand("hwclock -w")
+	return err
+}
+
+// configureNTP 按设备上的时间同步服务写入NTP服务器并启用同步
+func configureNTP(runner version.IFlashTool, service string, servers []string) error {
+	switch service {
+	case "chrony":
+		var lines []string
+		for _, server := range servers {
+			lines = append(lines, fmt.Sprintf("server %s iburst", server))
+		}
+		if _, err := runner.RunAndWaitCommand("mkdir -p /etc/chrony/sources.d"); err != nil {
+			return err
+		}
+		if err := runner.UploadContent([]byte(strings.Join(lines, "\n")+"\n"), chronySources); err != nil {
+			return err
+		}
+		_, err := runner.RunAndWaitCommand("chronyc reload sources || systemctl restart chrony")
+		return err
+	case "systemd-timesyncd":
+		if _, err := runner.RunAndWaitCommand("mkdir -p /etc/systemd/timesyncd.conf.d"); err != nil {
+			return err
+		}
+		content := fmt.Sprintf("[Time]\nNTP=%s\n", strings.Join(servers, " "))
+		if err := runner.UploadContent([]byte(content), timesyncdConfig); err != nil {
+			return err
+		}
+		_, err := runner.RunAndWaitCommand("timedatectl set-ntp true && systemctl restart systemd-timesyncd")
+		return err
+	default:
+		runner.AppendOutput("设备上未安装 chrony 或 systemd-timesyncd，无法配置NTP服务器")
+		return nil
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "是"
+	}
+	return "否"
+}
@@ -19,6 +19,7 @@ import (
 	"io"
 	"net"
 	"os"
+	"path"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -50,6 +51,7 @@ type FirmwareFlashTool struct {
 	lock      sync.Mutex
 
 	syncTime     bool  // 是否同步时间
+	dryRun       bool  // 是否预演模式
 	updateStatus int32 // 更新状态
 
 	// 用于管理命令执行
@@ -66,42 +68,39 @@ type FirmwareFlashTool struct {
 	outputScroll       *container.Scroll // 输出框
 	ipEntry            *widget.Entry     // IP
 	syncTimeCheck      *widget.Check     // 同步时间
+	dryRunCheck        *widget.Check     // 预演模式
 	snEntry            *widget.Entry     // SN输入框
 	downloadButton     *widget.Button    // 下载初始配置按钮
 	versionSelect      *widget.Select    // 版本
 	connButton         *widget.Button    // 连接按钮
 	flashButton        *widget.Button    // 刷写按钮
+	migrateButton      *widget.Button    // v2迁移到v3按钮
+	deltaButton        *widget.Button    // 差分更新按钮
+	syncButton         *widget.Button    // 增量同步按钮
 	updateButton       *widget.Button    // 检查更新按钮
-	net1Select         *widget.Select    // NET1选择框
-	net1AddressEntry   *widget.Entry     // NET1地址输入框
-	net1NetmaskEntry   *widget.Entry     // NET1子网掩码输入框
-	net1GatewayEntry   *widget.Entry     // NET1网关输入框
-	net2Select         *widget.Select    // NET2选择框
-	net2AddressEntry   *widget.Entry     // NET2地址输入框
-	net2NetmaskEntry   *widget.Entry     // NET2子网掩码输入框
-	net2GatewayEntry   *widget.Entry     // NET2网关输入框
+	passwordButton     *widget.Button    // 主密码按钮
+	policyButton       *widget.Button    // 配置有效期按钮
+	networkEditor      *NetworkEditor    // 网络配置编辑器
+	timeSettings       *TimeSettings     // 时区及NTP设置
+	statusDashboard    *StatusDashboard  // 设备状态面板
 	helpLabel          *widget.Label
 	helpScroll         *container.Scroll
 	*ConnStatusDisplay // 用于显示SSH连接状态
 }
 
 func NewFirmwareFlashTool() *FirmwareFlashTool {
-	return &FirmwareFlashTool{
+	t := &FirmwareFlashTool{
 		app:               app.New(),
 		window:            nil,
 		output:            widget.NewMultiLineEntry(),
 		ipEntry:           widget.NewEntry(),
 		snEntry:           widget.NewEntry(),
-		net1AddressEntry:  widget.NewEntry(),
-		net1NetmaskEntry:  widget.NewEntry(),
-		net1GatewayEntry:  widget.NewEntry(),
-		net2AddressEntry:  widget.NewEntry(),
-		net2NetmaskEntry:  widget.NewEntry(),
-		net2GatewayEntry:  widget.NewEntry(),
 		ConnStatusDisplay: NewConnStatusDisplay(),
-		net1Select:        widget.NewSelect([]string{"WAN", "LAN", ""}, nil),
-		net2Select:        widget.NewSelect([]string{"WAN", "LAN", ""}, nil),
 	}
+	t.networkEditor = NewNetworkEditor(t)
+	t.timeSettings = NewTimeSettings(t)
+	t.statusDashboard = NewStatusDashboard(t)
+	return t
 }
 func (t *FirmwareFlashTool) Run() {
 	t.window = t.app.NewWindow("EM500 初始化工具v1.1")
@@ -120,6 +119,11 @@ func (t *FirmwareFlashTool) setupUI() {
 	t.preloadTabs(tabs)
 	t.window.SetFixedSize(true)
 	t.window.Resize(fyne.NewSize(600, 640))
+
+	// 配置已加密时，启动后先输入主密码
+	if version.SettingEncryptionEnabled() {
+		t.showMasterPasswordDialog()
+	}
 }
 
 func (t *FirmwareFlashTool) setupEntries() {
@@ -130,70 +134,17 @@ func (t *FirmwareFlashTool) setupEntries() {
 func (t *FirmwareFlashTool) setupPlaceholders() {
 	t.output.SetPlaceHolder("输出...")
 	t.snEntry.SetPlaceHolder("请输入设备SN")
-	t.net1AddressEntry.SetPlaceHolder("输入 LAN1 IP 地址，不可为空")
-	t.net1NetmaskEntry.SetPlaceHolder("输入 LAN1 子网掩码，不可为空")
-	t.net1GatewayEntry.SetPlaceHolder("输入 LAN1 网关地址，可为空")
-	t.net2AddressEntry.SetPlaceHolder("输入 LAN2 IP 地址，不可为空")
-	t.net2NetmaskEntry.SetPlaceHolder("输入 LAN2 子网掩码，不可为空")
-	t.net2GatewayEntry.SetPlaceHolder("输入 LAN2 网关地址，可为空")
 }
 
 func (t *FirmwareFlashTool) setupSelects() {
 	versions := []string{"v2", "v3"}
 
 	t.versionSelect = widget.NewSelect(versions, func(value string) {
-		switch value {
-		case "v2":
-			t.AppendOutput("切换到v2版本")
-			t.version = version.NewV2(t, t.window)
-		case "v3":
-			t.AppendOutput("切换到v3版本")
-			t.version = version.NewV3(t, t.window)
-		}
+		t.AppendOutput(fmt.Sprintf("切换到%s版本", value))
+		t.version = t.newVersion(value, t)
 	})
 	t.versionSelect.Selected = "v3"
-	t.version = version.NewV3(t, t.window)
-
-	hideLAN1Input := func() {
-		t.net1AddressEntry.Hide()
-		t.net1NetmaskEntry.Hide()
-		t.net1GatewayEntry.Hide()
-	}
-	showLAN1Input := func() {
-		t.net1AddressEntry.Show()
-		t.net1NetmaskEntry.Show()
-		t.net1GatewayEntry.Show()
-	}
-	hideLAN2Input := func() {
-		t.net2AddressEntry.Hide()
-		t.net2NetmaskEntry.Hide()
-		t.net2GatewayEntry.Hide()
-	}
-	showLAN2Input := func() {
-		t.net2AddressEntry.Show()
-		t.net2NetmaskEntry.Show()
-		t.net2GatewayEntry.Show()
-	}
-
-	t.net1Select.OnChanged = func(selected string) {
-		if selected == "LAN" {
-			showLAN1Input()
-		} else {
-			hideLAN1Input()
-		}
-	}
-
-	t.net2Select.OnChanged = func(selected string) {
-		if selected == "LAN" {
-			showLAN2Input()
-		} else {
-			hideLAN2Input()
-		}
-	}
-
-	// 默认隐藏 LAN 输入框
-	hideLAN1Input()
-	hideLAN2Input()
+	t.version = t.newVersion("v3", t)
 }
 
 func (t *FirmwareFlashTool) setupButtons() {
@@ -210,6 +161,7 @@ func (t *FirmwareFlashTool) setupButtons() {
 		sn := t.snEntry.Text
 		if err := t.version.DownloadConfig(sn); err != nil {
 			t.AppendOutput(fmt.Sprintf("下载配置失败! 设备SN: %s, 错误信息: %s", sn, err.Error()))
+			t.warnUnregistered(sn, err)
 		} else {
 			t.AppendOutput(fmt.Sprintf("下载配置成功! 设备SN: %s", sn))
 		}
@@ -222,12 +174,71 @@ func (t *FirmwareFlashTool) setupButtons() {
 				t.AppendOutput("连接失败: " + err.Error())
 			} else {
 				t.AppendOutput("连接已建立!")
+				t.checkDeviceSN()
 			}
 		}()
 	})
 
 	t.flashButton = widget.NewButton("开始刷写", func() {
-		t.version.FlashFirmware(t.snEntry.Text, true)
+		sn := t.snEntry.Text
+		// 先核对SN，再检查主机上的初始配置是否缺失或过期
+		t.confirmSN(sn, func() {
+			t.checkSettingFreshness(t.version, t.versionSelect.Selected, sn, func() {
+				v := t.version
+				if t.dryRun {
+					t.AppendOutput("预演模式: 以下操作不会在设备上执行")
+					v = t.newVersion(t.versionSelect.Selected, t.flashTool())
+				}
+				v.FlashFirmware(sn)
+			})
+		})
+	})
+
+	t.deltaButton = widget.NewButton("差分更新", func() {
+		sn := t.snEntry.Text
+		t.confirmSN(sn, func() {
+			v := t.version
+			if t.dryRun {
+				t.AppendOutput("预演模式: 以下操作不会在设备上执行")
+				v = t.newVersion(t.versionSelect.Selected, t.flashTool())
+			}
+			v.DeltaUpdate(sn)
+		})
+	})
+
+	t.syncButton = widget.NewButton("增量同步", func() {
+		sn := t.snEntry.Text
+		t.confirmSN(sn, func() {
+			v := t.version
+			if t.dryRun {
+				t.AppendOutput("预演模式: 以下操作不会在设备上执行")
+				v = t.newVersion(t.versionSelect.Selected, t.flashTool())
+			}
+			v.SyncFirmware(sn)
+		})
+	})
+
+	t.migrateButton = widget.NewButton("从v2迁移到v3", func() {
+		sn := t.snEntry.Text
+		t.confirmSN(sn, func() {
+			if t.dryRun {
+				t.AppendOutput("预演模式: 以下操作不会在设备上执行")
+			}
+			// 非预演模式下复用当前v3实例，避免重复执行
+			v3, ok := t.version.(*version.V3)
+			if !ok || t.dryRun {
+				v3 = version.NewV3(t.flashTool(), t.window)
+			}
+			v3.MigrateFromV2(sn)
+		})
+	})
+
+	t.passwordButton = widget.NewButton("主密码(加密配置)", func() {
+		t.showMasterPasswordDialog()
+	})
+
+	t.policyButton = widget.NewButton("配置有效期", func() {
+		t.showSettingPolicyDialog()
 	})
 
 	t.updateButton = widget.NewButton("检查更新", func() {
@@ -241,11 +252,15 @@ func (t *FirmwareFlashTool) setupButtons() {
 }
 
 func (t *FirmwareFlashTool) setupTabs() *container.AppTabs {
-	t.syncTimeCheck = widget.NewCheck("同步主机时间", func(check bool) {
+	t.syncTimeCheck = widget.NewCheck("设备无网络时同步主机时间", func(check bool) {
 		t.syncTime = check
 	})
 	t.syncTimeCheck.SetChecked(true)
 
+	t.dryRunCheck = widget.NewCheck("预演模式(仅列出操作，不执行)", func(check bool) {
+		t.dryRun = check
+	})
+
 	ipBox := container.NewVBox(
 		container.NewHBox(widget.NewLabel("当前连接状态:"), &t.ConnStatusDisplay.Text),
 		widget.NewLabel("目标设备IP:"),
@@ -267,6 +282,8 @@ func (t *FirmwareFlashTool) setupTabs() *container.AppTabs {
 			t.updateButton,
 			container.NewVBox(widget.NewLabel("目标设备SN:"), t.snEntry),
 			t.downloadButton,
+			t.passwordButton,
+			t.policyButton,
 		),
 		outputBox,
 	)
@@ -276,28 +293,59 @@ func (t *FirmwareFlashTool) setupTabs() *container.AppTabs {
 			ipBox,
 			container.NewVBox(widget.NewLabel("目标设备SN:"), t.snEntry),
 			container.NewVBox(widget.NewLabel("选择版本:"), t.versionSelect),
+			t.dryRunCheck,
 			t.flashButton,
+			t.syncButton,
+			t.deltaButton,
+			t.migrateButton,
 		),
 		outputBox,
 	)
 
 	tab3Content := container.NewHSplit(
-		container.NewVBox(
+		container.NewVScroll(container.NewVBox(
 			ipBox,
+			t.timeSettings.Content(),
 			container.NewHBox(t.syncTimeCheck),
-			widget.NewLabel("选择 NET1 网口配置："),
-			t.net1Select,
-			t.net1AddressEntry,
-			t.net1NetmaskEntry,
-			t.net1GatewayEntry,
-			widget.NewLabel("选择 NET2 网口配置："),
-			t.net2Select,
-			t.net2AddressEntry,
-			t.net2NetmaskEntry,
-			t.net2GatewayEntry,
+			t.dryRunCheck,
+			t.networkEditor.Content(),
 			widget.NewButton("更新设置", func() {
 				t.updateSetting()
 			}),
+			widget.NewButton("读取设备信息", func() {
+				go t.readInventory()
+			}),
+			container.NewGridWithColumns(3,
+				widget.NewButton("重启设备", t.rebootDevice),
+				widget.NewButton("关闭设备", t.shutdownDevice),
+				widget.NewButton("重启全部服务", t.restartAllServices),
+			),
+		)),
+		outputBox,
+	)
+
+	statusContent := container.NewHSplit(
+		container.NewBorder(ipBox, nil, nil, nil, t.statusDashboard.Content()),
+		outputBox,
+	)
+
+	serviceContent := container.NewHSplit(
+		container.NewBorder(container.NewVBox(ipBox, t.dryRunCheck), nil, nil, nil, NewServiceManager(t).Content()),
+		outputBox,
+	)
+
+	logContent := container.NewHSplit(
+		container.NewBorder(container.NewVBox(ipBox, container.NewVBox(widget.NewLabel("目标设备SN:"), t.snEntry)), nil, nil, nil, NewLogViewer(t).Content()),
+		outputBox,
+	)
+
+	compareContent := container.NewHSplit(
+		container.NewVBox(
+			ipBox,
+			container.NewVBox(widget.NewLabel("目标设备SN:"), t.snEntry),
+			container.NewVBox(widget.NewLabel("选择版本:"), t.versionSelect),
+			t.dryRunCheck,
+			t.configCompareBox(),
 		),
 		outputBox,
 	)
@@ -309,16 +357,47 @@ func (t *FirmwareFlashTool) setupTabs() *container.AppTabs {
 
 	return container.NewAppTabs(
 		container.NewTabItem("更新管理", tab1Content),
+		container.NewTabItem("批量下载", NewBulkDownloader(t).Content()),
 		container.NewTabItem("固件刷写", tab2Content),
 		container.NewTabItem("设备管理", tab3Content),
+		container.NewTabItem("设备状态", statusContent),
+		container.NewTabItem("服务管理", serviceContent),
+		container.NewTabItem("设备日志", logContent),
+		container.NewTabItem("配置编辑", NewSettingEditor(t).Content()),
+		container.NewTabItem("串口配置", container.NewHSplit(NewSerialEditor(t).Content(), outputBox)),
+		container.NewTabItem("配置对比", compareContent),
 		container.NewTabItem("帮助文档", container.NewVBox(
 			t.helpScroll,
 		)),
 	)
 }
 
+// newVersion 创建指定版本的固件管理实例
+func (t *FirmwareFlashTool) newVersion(name string, flashTool version.IFlashTool) version.IFirmwareVersion {
+	switch name {
+	case "v2":
+		return version.NewV2(flashTool, t.window)
+	default:
+		return version.NewV3(flashTool, t.window)
+	}
+}
+
+// flashTool 获取设备操作实例，预演模式下只记录操作不执行
+func (t *FirmwareFlashTool) flashTool() version.IFlashTool {
+	if t.dryRun {
+		return NewDryRunTool(t)
+	}
+	return t
+}
+
 func (t *FirmwareFlashTool) preloadTabs(tabs *container.AppTabs) {
 	// 提前加载标签页内容
+	tabs.SelectIndex(9)
+	tabs.SelectIndex(8)
+	tabs.SelectIndex(6)
+	tabs.SelectIndex(5)
+	tabs.SelectIndex(4)
+	tabs.SelectIndex(3)
 	tabs.SelectIndex(2)
 	tabs.SelectIndex(1)
 	tabs.SelectIndex(0)
@@ -335,12 +414,16 @@ func (t *FirmwareFlashTool) connToDevice() error {
 		t.sshClient = nil
 		t.ConnStatusDisplay.SetStatus(false)
 
-		if t.sshCancel != nil {
-			t.sshCancel()
-		}
-
 		time.Sleep(500 * time.Millisecond)
 	}
+	// 连接已断开时也需停止旧连接的监控及状态刷新
+	if t.sshCancel != nil {
+		t.sshCancel()
+		t.sshCancel = nil
+	}
+	// 读取的设备配置只属于旧连接
+	t.networkEditor.reset()
+	t.timeSettings.reset()
 
 	t.AppendOutput(fmt.Sprintf("正在建立与设备（IP：`%s`）的SSH连接", t.ipEntry.Text))
 	sshConfig := &ssh.ClientConfig{
@@ -372,8 +455,9 @@ func (t *FirmwareFlashTool) connToDevice() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	t.sshCancel = cancel
 
-	// 启动监控连接
+	// 启动监控连接，定时刷新设备状态
 	go t.monitorConnection(ctx)
+	go t.statusDashboard.poll(ctx)
 
 	return nil
 }
@@ -405,6 +489,11 @@ func (t *FirmwareFlashTool) keepalive() bool {
 		if err != nil {
 			t.sshClient.Close()
 			t.sshClient = nil
+			// 停止设备状态刷新
+			if t.sshCancel != nil {
+				t.sshCancel()
+				t.sshCancel = nil
+			}
 
 			t.AppendOutput("检测到连接已断开!")
 			t.ConnStatusDisplay.SetStatus(false)
@@ -423,7 +512,28 @@ func (t *FirmwareFlashTool) updateSetting() {
 		return
 	}
 
-	conf := dialog.NewConfirm("更新设置", "您确定要更新设置吗？", func(confirmed bool) {
+	// 写入前先校验网络配置
+	connectedIP := strings.TrimSpace(t.ipEntry.Text)
+	content, changed, warning, err := t.networkEditor.render(connectedIP)
+	var rollbackMinutes int
+	if err == nil && changed {
+		rollbackMinutes, err = t.networkEditor.rollbackMinutes()
+	}
+	if err != nil {
+		atomic.StoreInt32(&t.updateStatus, 0)
+		dialog.ShowError(err, t.window)
+		return
+	}
+	message := "您确定要更新设置吗？"
+	if changed {
+		message += fmt.Sprintf("\n\n设备重启后 %d 分钟内需重新连接确认网络配置，否则将自动恢复原配置。", rollbackMinutes)
+	}
+	if warning != "" {
+		t.AppendOutput("警告: " + warning)
+		message += "\n\n警告: " + warning
+	}
+
+	conf := dialog.NewConfirm("更新设置", message, func(confirmed bool) {
 		if !confirmed {
 			atomic.StoreInt32(&t.updateStatus, 0)
 			return
@@ -441,58 +551,47 @@ func (t *FirmwareFlashTool) updateSetting() {
 				}
 			}()
 
-			var cmd string
 			var reboot bool
+			runner := t.flashTool()
 
-			fmt.Println(t.net1Select.Selected)
-
-			// 同步时间
-			if t.syncTime {
-				t.AppendOutput("正在同步当前主机时间...")
-				dateTime := time.Now()
-				cmd = fmt.Sprintf("date -s \"%s\"", dateTime.Format("2006-01-02 15:04:05"))
-				if _, err = t.RunAndWaitCommand(cmd); err != nil {
-					return
-				}
-
-				// 写入硬件时钟
-				cmd = fmt.Sprintf("hwclock -w")
-				if _, err = t.RunAndWaitCommand(cmd); err != nil {
-					return
-				}
+			// 设置时区、NTP，设备未与NTP服务器同步时同步主机时间
+			if err = t.timeSettings.apply(runner, t.syncTime); err != nil {
+				return
 			}
 
-			// 修改网络配置
-			net1Type := t.net1Select.Selected
-			net2Type := t.net2Select.Selected
-			if (net1Type != "" && net2Type == "") || (net1Type == "" && net2Type != "") {
-				err = fmt.Errorf("两个网口必须一起配置！")
-				return
-			} else if net1Type != "" && net2Type != "" {
-				if (net1Type == "LAN" && (t.net1AddressEntry.Text == "" || t.net1NetmaskEntry.Text == "")) ||
-					(net2Type == "LAN" && (t.net2AddressEntry.Text == "" || t.net2NetmaskEntry.Text == "")) {
-					err = fmt.Errorf("请确保两个网口都已配置并填写完整！")
-					return
+			// 修改网络配置，只在读取并修改后写入
+			if changed {
+				if t.dryRun {
+					t.AppendOutput("[预演] " + interfacesPath + " 内容:\n" + content)
 				}
 
-				var netConfig []string
-				netConfig = append(netConfig, generateNetworkConfig("eth0", net1Type, t.net1AddressEntry.Text, t.net1NetmaskEntry.Text, t.net1GatewayEntry.Text))
-				netConfig = append(netConfig, generateNetworkConfig("eth1", net2Type, t.net2AddressEntry.Text, t.net2NetmaskEntry.Text, t.net2GatewayEntry.Text))
-
 				// 备份原始配置
-				if _, err = t.RunAndWaitCommand("cp /etc/network/interfaces /etc/network/interfaces.bak.$(date +%Y%m%d%H%M%S)"); err != nil {
+				if _, err = runner.RunAndWaitCommand(fmt.Sprintf("cp %s %s.bak.$(date +%%Y%%m%%d%%H%%M%%S)", interfacesPath, interfacesPath)); err != nil {
+					return
+				}
+				// 未确认时自动恢复原配置
+				if err = armNetworkRollback(runner, rollbackMinutes); err != nil {
 					return
 				}
 				// 写入配置文件
-				if _, err = t.RunAndWaitCommand(fmt.Sprintf("echo \"%s\" > /etc/network/interfaces", strings.Join(netConfig, "\n\n"))); err != nil {
+				if err = runner.UploadContent([]byte(content), interfacesPath); err != nil {
 					return
 				}
 				reboot = true
 			}
 
+			if t.dryRun {
+				t.AppendOutput(fmt.Sprintf("[预演] 是否重启设备: %v", reboot))
+			}
+
 			if reboot {
 				t.AppendOutput("系统设置更新成功，正在重启设备...")
-				if _, err = t.RunAndWaitCommand("reboot"); err != nil {
+				_, err = runner.RunAndWaitCommand("reboot")
+				// 重启时连接断开也可能返回错误，都需等待确认
+				if changed && !t.dryRun {
+					go t.awaitNetworkConfirm(t.networkEditor.nextAddress(connectedIP), connectedIP, rollbackMinutes)
+				}
+				if err != nil {
 					return
 				}
 			}
@@ -505,19 +604,6 @@ func (t *FirmwareFlashTool) updateSetting() {
 	conf.Show()
 }
 
-func generateNetworkConfig(interfaceName, netType, address, netmask, gateway string) string {
-	if netType == "WAN" {
-		return fmt.Sprintf("auto %s\niface %s inet dhcp", interfaceName, interfaceName)
-	} else {
-		content := fmt.Sprintf("auto %s\niface %s inet static\naddress %s\nnetmask %s", interfaceName, interfaceName, address, netmask)
-		if gateway != "" {
-			return fmt.Sprintf("%s\ngateway %s", content, gateway)
-		}
-
-		return content
-	}
-}
-
 func (t *FirmwareFlashTool) RunAndWaitCommand(cmd string) (string, error) {
 	return t.runCommand(cmd)
 }
@@ -597,6 +683,142 @@ func (t *FirmwareFlashTool) runCommand(cmd string) (string, error) {
 	}
 }
 
+// RunQuietCommand 执行命令并返回标准输出，不输出日志
+func (t *FirmwareFlashTool) RunQuietCommand(cmd string) (string, error) {
+	client := t.sshClient
+	if client == nil {
+		return "", errors.New("未连接到设备，请先与设备建立连接")
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	output, err := session.Output(cmd)
+	return string(output), err
+}
+
+// StreamFromCommand 执行命令，并将标准输出流式交给 read 处理，不输出日志
+func (t *FirmwareFlashTool) StreamFromCommand(cmd string, read func(r io.Reader) error) error {
+	client := t.sshClient
+	if client == nil {
+		return errors.New("未连接到设备，请先与设备建立连接")
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := session.Start(cmd); err != nil {
+		return err
+	}
+
+	// read 提前返回时关闭会话，结束设备上的命令
+	if err := read(stdout); err != nil {
+		session.Close()
+		return err
+	}
+	return session.Wait()
+}
+
+// StreamToCommand 执行命令，并将 write 生成的数据流式写入命令的标准输入
+func (t *FirmwareFlashTool) StreamToCommand(cmd string, write func(w io.Writer) error) error {
+	client := t.sshClient
+	if client == nil {
+		return errors.New("未连接到设备，请先与设备建立连接")
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	outputBuf := new(bytes.Buffer)
+	go t.printOutput(stdout, outputBuf)
+	go t.printOutput(stderr, outputBuf)
+
+	t.AppendOutput("执行命令: " + cmd)
+	if err := session.Start(cmd); err != nil {
+		return err
+	}
+
+	if err := write(stdin); err != nil {
+		session.Close()
+		return err
+	}
+	if err := stdin.Close(); err != nil {
+		return err
+	}
+
+	return session.Wait()
+}
+
+// StreamCommand 执行命令并逐行回调标准输出，直到命令结束或ctx取消，不输出日志
+func (t *FirmwareFlashTool) StreamCommand(ctx context.Context, cmd string, onLine func(line string)) error {
+	client := t.sshClient
+	if client == nil {
+		return errors.New("未连接到设备，请先与设备建立连接")
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := session.Start(cmd); err != nil {
+		return err
+	}
+
+	// ctx取消时关闭会话，结束读取
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Close()
+		case <-done:
+		}
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		onLine(scanner.Text())
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return session.Wait()
+}
+
 // printOutput 读取并打印 SSH 输出
 func (t *FirmwareFlashTool) printOutput(reader io.Reader, outputBuf *bytes.Buffer) {
 	scanner := bufio.NewScanner(reader)
@@ -631,3 +853,19 @@ func (t *FirmwareFlashTool) UploadFile(localPath, remotePath string) error {
 	t.AppendOutput(fmt.Sprintf("文件上传成功: %s -> %s", localPath, remotePath))
 	return err
 }
+
+// UploadContent 将内存中的内容上传到设备，用于上传解密后的配置，避免明文落盘
+func (t *FirmwareFlashTool) UploadContent(content []byte, remotePath string) error {
+	session, err := t.sshClient.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if err := scp.Copy(int64(len(content)), 0644, path.Base(remotePath), bytes.NewReader(content), remotePath, session); err != nil {
+		return err
+	}
+
+	t.AppendOutput(fmt.Sprintf("文件上传成功: %s", remotePath))
+	return nil
+}
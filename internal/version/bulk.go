@@ -0,0 +1,87 @@
+package version
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 批量下载的结果状态
+const (
+	DownloadPending       = "等待中"
+	DownloadRunning       = "下载中"
+	DownloadOK            = "成功"
+	DownloadNotRegistered = "未注册"
+	DownloadDecryptFailed = "解密失败"
+	DownloadHTTPError     = "HTTP错误"
+	DownloadChecksumError = "校验失败"
+	DownloadFailed        = "失败"
+)
+
+var snSeparator = regexp.MustCompile(`[\s,;，；]+`)
+
+// ParseSNList 解析粘贴或导入的SN列表，支持换行、空格、逗号、分号分隔，去除重复
+func ParseSNList(text string) []string {
+	var sns []string
+	seen := make(map[string]bool)
+	for _, sn := range snSeparator.Split(text, -1) {
+		sn = strings.TrimSpace(sn)
+		if sn == "" || seen[sn] {
+			continue
+		}
+		seen[sn] = true
+		sns = append(sns, sn)
+	}
+	return sns
+}
+
+// DownloadStatus 根据下载配置返回的错误判断结果状态
+func DownloadStatus(err error) string {
+	var httpErr *HTTPError
+	var decryptErr *DecryptError
+	var checksumErr *ChecksumError
+	var urlErr *url.Error
+	switch {
+	case err == nil:
+		return DownloadOK
+	case errors.Is(err, ErrNotRegistered):
+		return DownloadNotRegistered
+	case errors.As(err, &decryptErr):
+		return DownloadDecryptFailed
+	case errors.As(err, &checksumErr):
+		return DownloadChecksumError
+	case errors.As(err, &httpErr), errors.As(err, &urlErr):
+		return DownloadHTTPError
+	default:
+		return DownloadFailed
+	}
+}
+
+// BulkDownloadConfig 使用workers个并发下载多个设备的配置，每个SN开始和完成时调用progress
+func BulkDownloadConfig(v IFirmwareVersion, sns []string, workers int, progress func(sn, status string, err error)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sn := range jobs {
+				progress(sn, DownloadRunning, nil)
+				err := v.DownloadConfig(sn)
+				progress(sn, DownloadStatus(err), err)
+			}
+		}()
+	}
+
+	for _, sn := range sns {
+		jobs <- sn
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,223 @@
+package version
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	supportDir          = "support" // 主机上诊断包的存放目录
+	supportLogDays      = 7         // 只收集最近几天修改过的日志
+	supportJournalLines = 1000      // 每个服务收集的 journal 行数
+	supportMaxFileBytes = 200 << 20 // 诊断包中日志及配置的总大小上限
+)
+
+// supportConfigPaths 诊断包中收集的设备配置，不包含含有账号密码的初始配置
+var supportConfigPaths = []string{
+	v3RootDir + "/data/config",
+	v2RootDir + "/data/config",
+	remoteFrpcConfig,
+	"/etc/network/interfaces",
+}
+
+// secretPattern 配置中需要隐藏的密钥、密码等字段，如 token、password、pass、pwd、mqtt_pwd、server_key
+var secretPattern = regexp.MustCompile(`(?im)^(\s*"?[\w.-]*(token|pass|pwd|psk|secret|key)[\w.-]*"?\s*[:=]\s*).+$`)
+
+// secretOptionPattern ifupdown等以空格分隔的选项中的密钥，如 wpa-psk、wpa-password、wireless-key
+var secretOptionPattern = regexp.MustCompile(`(?im)^(\s*[\w.-]*(pass|pwd|psk|secret|key)[\w.-]*[ \t]+)[^\s:=].*$`)
+
+// redactSecrets 隐藏配置中的密钥、密码等字段的值
+func redactSecrets(data []byte) []byte {
+	data = secretPattern.ReplaceAll(data, []byte("${1}******"))
+	return secretOptionPattern.ReplaceAll(data, []byte("${1}******"))
+}
+
+// CollectSupportBundle 收集设备日志、配置、服务状态及journal，打包保存到 support/<sn>/<时间>.tar.gz
+// 优先使用设备上读取到的SN，读取不到时使用sn；单项收集失败时记录到 errors.txt 并继续，返回诊断包路径
+func CollectSupportBundle(flashTool IFlashTool, sn string) (path string, err error) {
+	if _, err := flashTool.RunQuietCommand("true"); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	var failures []string
+	fail := func(item string, err error) {
+		failures = append(failures, fmt.Sprintf("%s: %v", item, err))
+	}
+
+	// 设备信息，用于确定诊断包的目录
+	inv, invErr := CollectInventory(flashTool)
+	if invErr != nil {
+		fail("设备信息", invErr)
+	} else if inv.SN != "" {
+		sn = inv.SN
+	}
+
+	// 边收集边写入文件，日志不在内存中缓存
+	dir := filepath.Join(supportDir, snDirName(sn))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path = filepath.Join(dir, now.Format("20060102150405")+".tar.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+			path = ""
+		}
+	}()
+
+	gw := gzip.NewWriter(file)
+	tw := tar.NewWriter(gw)
+
+	add := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if invErr == nil {
+		data, _ := json.MarshalIndent(inv, "", "  ")
+		if err := add("inventory.json", data); err != nil {
+			return "", err
+		}
+	}
+
+	// 运行状态
+	if status, err := CollectStatus(flashTool); err != nil {
+		fail("运行状态", err)
+	} else {
+		data, _ := json.MarshalIndent(status, "", "  ")
+		if problems := status.Problems(); len(problems) > 0 {
+			data = append(data, []byte("\n\n异常:\n"+strings.Join(problems, "\n")+"\n")...)
+		}
+		if err := add("status.txt", data); err != nil {
+			return "", err
+		}
+	}
+
+	// 服务状态及journal
+	if services, err := CollectServices(flashTool, GatewayServices); err != nil {
+		fail("服务状态", err)
+	} else {
+		var lines []string
+		for _, service := range services {
+			lines = append(lines, service.String())
+		}
+		if err := add("services.txt", []byte(strings.Join(lines, "\n")+"\n")); err != nil {
+			return "", err
+		}
+	}
+	for _, name := range GatewayServices {
+		output, err := ServiceJournal(flashTool, name, supportJournalLines)
+		if err != nil {
+			fail("journal "+name, err)
+			continue
+		}
+		if err := add(fmt.Sprintf("journal/%s.log", name), []byte(output)); err != nil {
+			return "", err
+		}
+	}
+
+	// 日志及配置，在设备上打包后流式读取并逐个写入诊断包，配置中的密钥隐藏
+	cmd := fmt.Sprintf("(find %s -maxdepth 1 -type f -mtime -%d 2>/dev/null; find %s -type f 2>/dev/null) | tar cf - -T - 2>/dev/null; true",
+		strings.Join(LogDirs, " "), supportLogDays, strings.Join(supportConfigPaths, " "))
+	err = flashTool.StreamFromCommand(cmd, func(r io.Reader) error {
+		return copyRemoteFiles(tw, r, supportMaxFileBytes)
+	})
+	if errors.Is(err, errSupportTooLarge) {
+		fail("日志及配置", fmt.Errorf("%v，超出部分未收集", err))
+	} else if err != nil {
+		fail("日志及配置", err)
+	}
+
+	if len(failures) > 0 {
+		if err := add("errors.txt", []byte(strings.Join(failures, "\n")+"\n")); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gw.Close(); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// errSupportTooLarge 设备上的日志及配置超过诊断包的大小上限
+var errSupportTooLarge = fmt.Errorf("日志及配置超过 %d MB", supportMaxFileBytes>>20)
+
+// copyRemoteFiles 将设备上打包的文件写入诊断包，日志放在 logs/ 下，配置放在 config/ 下
+// 文件总大小超过limit时停止并返回errSupportTooLarge，已写入的文件保留
+func copyRemoteFiles(tw *tar.Writer, r io.Reader, limit int64) error {
+	tr := tar.NewReader(r)
+	var total int64
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if total+header.Size > limit {
+			return errSupportTooLarge
+		}
+		total += header.Size
+
+		name := "/" + strings.TrimPrefix(header.Name, "/")
+		prefix := "config"
+		for _, dir := range LogDirs {
+			if strings.HasPrefix(name, dir+"/") {
+				prefix = "logs"
+			}
+		}
+
+		// 日志直接复制，配置较小，读入内存隐藏密钥
+		var content io.Reader = tr
+		size := header.Size
+		if prefix == "config" {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			data = redactSecrets(data)
+			content, size = bytes.NewReader(data), int64(len(data))
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    prefix + name,
+			Mode:    0644,
+			Size:    size,
+			ModTime: header.ModTime,
+		}); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, content); err != nil {
+			return err
+		}
+	}
+}
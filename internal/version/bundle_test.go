@@ -0,0 +1,130 @@
+package version
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`auth.token = "abc"`, `auth.token = ******`},
+		{`  password: xyz`, `  password: ******`},
+		{`    pass: "1234"   # 密码`, `    pass: ******`},
+		{`  pwd: "mqtt_cf1234568"`, `  pwd: ******`},
+		{`"mqtt_pwd": "q",`, `"mqtt_pwd": ******`},
+		{`"server_key": "XJ9R"`, `"server_key": ******`},
+		{`serverAddr = "1.2.3.4"`, `serverAddr = "1.2.3.4"`},
+		{`  user: "mqtt_2cifang"`, `  user: "mqtt_2cifang"`},
+		{`    wpa-psk Cf@2021wifi`, `    wpa-psk ******`},
+		{`    wireless-key s:abcde`, `    wireless-key ******`},
+		{`    wpa-ssid 2cifang`, `    wpa-ssid 2cifang`},
+		{`    address 192.168.2.136/24`, `    address 192.168.2.136/24`},
+	}
+	for _, tt := range tests {
+		if got := string(redactSecrets([]byte(tt.line))); got != tt.want {
+			t.Errorf("redactSecrets(%q) = %q, 期望 %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+// 随程序发布的组件配置中的密码应全部隐藏
+func TestRedactShippedConfig(t *testing.T) {
+	data, err := os.ReadFile("../../v2_install/config/cgCollector.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redacted := string(redactSecrets(data))
+	for _, secret := range []string{"mqtt_cf1234568", `"1234"`} {
+		if strings.Contains(redacted, secret) {
+			t.Errorf("诊断包中的 cgCollector.yaml 未隐藏 %s", secret)
+		}
+	}
+}
+
+// 网络配置中以空格分隔的WiFi密码应隐藏，地址等其他配置保留
+func TestRedactInterfaces(t *testing.T) {
+	data := `# interfaces(5) file used by ifup(8) and ifdown(8)
+source /etc/network/interfaces.d/*
+
+auto lo
+iface lo inet loopback
+
+auto eth1
+iface eth1 inet static
+    address 192.168.2.136
+    netmask 255.255.255.0
+    gateway 192.168.2.1
+
+allow-hotplug wlan0
+iface wlan0 inet dhcp
+    wpa-ssid "2cifang-office"
+    wpa-psk "Cf@2021wifi"
+    wpa-key-mgmt WPA-PSK
+`
+	redacted := string(redactSecrets([]byte(data)))
+	if strings.Contains(redacted, "Cf@2021wifi") {
+		t.Errorf("诊断包中的网络配置未隐藏 wpa-psk:\n%s", redacted)
+	}
+	for _, keep := range []string{"address 192.168.2.136", "netmask 255.255.255.0", "gateway 192.168.2.1", `wpa-ssid "2cifang-office"`, "source /etc/network/interfaces.d/*"} {
+		if !strings.Contains(redacted, keep) {
+			t.Errorf("诊断包中的网络配置缺少 %s:\n%s", keep, redacted)
+		}
+	}
+}
+
+// 设备上的日志及配置逐个写入诊断包，配置隐藏密钥，超过上限时停止
+func TestCopyRemoteFiles(t *testing.T) {
+	var remote bytes.Buffer
+	rw := tar.NewWriter(&remote)
+	files := []struct{ name, content string }{
+		{"datas/frpc/frpc.toml", "auth.token = \"abc\"\n"},
+		{"datas/cf_go_v3/data/log/cgCollector.log", strings.Repeat("INFO 采集完成\n", 100)},
+		{"datas/cf_go_v3/data/log/cgUpdater.log", strings.Repeat("INFO 检查更新\n", 100)},
+	}
+	for _, f := range files {
+		if err := rw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.content))}); err != nil {
+			t.Fatal(err)
+		}
+		rw.Write([]byte(f.content))
+	}
+	rw.Close()
+
+	var bundle bytes.Buffer
+	tw := tar.NewWriter(&bundle)
+	limit := int64(len(files[0].content) + len(files[1].content))
+	if err := copyRemoteFiles(tw, bytes.NewReader(remote.Bytes()), limit); !errors.Is(err, errSupportTooLarge) {
+		t.Fatalf("超过上限时应返回 errSupportTooLarge, 实际: %v", err)
+	}
+	tw.Close()
+
+	got := make(map[string]string)
+	tr := tar.NewReader(&bundle)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		got[header.Name] = string(data)
+	}
+	if content := got["config/datas/frpc/frpc.toml"]; content != "auth.token = ******\n" {
+		t.Errorf("frpc.toml = %q, 应隐藏token", content)
+	}
+	if content := got["logs/datas/cf_go_v3/data/log/cgCollector.log"]; content != files[1].content {
+		t.Errorf("cgCollector.log 内容不一致")
+	}
+	if _, ok := got["logs/datas/cf_go_v3/data/log/cgUpdater.log"]; ok {
+		t.Error("超过上限的日志不应写入诊断包")
+	}
+}
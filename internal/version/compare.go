@@ -0,0 +1,188 @@
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 配置差异类型
+const (
+	DiffErpOnly    = "仅ERP"
+	DiffDeviceOnly = "仅设备"
+	DiffChanged    = "不同"
+)
+
+// DiffEntry 配置中的一处差异，Path为完整字段路径，如 erp.mqtt_ip
+type DiffEntry struct {
+	Section string
+	Path    string
+	Kind    string
+	Erp     interface{}
+	Device  interface{}
+}
+
+func (d DiffEntry) String() string {
+	switch d.Kind {
+	case DiffErpOnly:
+		return fmt.Sprintf("%s [%s] ERP: %s", d.Path, d.Kind, diffValue(d.Erp))
+	case DiffDeviceOnly:
+		return fmt.Sprintf("%s [%s] 设备: %s", d.Path, d.Kind, diffValue(d.Device))
+	default:
+		return fmt.Sprintf("%s [%s] ERP: %s, 设备: %s", d.Path, d.Kind, diffValue(d.Erp), diffValue(d.Device))
+	}
+}
+
+// ConfigCompare ERP配置与设备上运行的配置的对比结果
+type ConfigCompare struct {
+	Erp    []byte      // ERP平台(或主机上已下载)的配置
+	Device []byte      // 设备上的配置
+	Diffs  []DiffEntry // 按字段路径排序的差异
+}
+
+// DiffJSON 按字段对比两份JSON配置，对象逐层对比，数组和其他值整体对比
+func DiffJSON(erp, device []byte) ([]DiffEntry, error) {
+	var a, b interface{}
+	if err := json.Unmarshal(erp, &a); err != nil {
+		return nil, fmt.Errorf("解析ERP配置失败: %v", err)
+	}
+	if err := json.Unmarshal(device, &b); err != nil {
+		return nil, fmt.Errorf("解析设备配置失败: %v", err)
+	}
+
+	var diffs []DiffEntry
+	diffValues("", a, b, &diffs)
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Path < diffs[j].Path
+	})
+	return diffs, nil
+}
+
+func diffValues(prefix string, a, b interface{}, diffs *[]DiffEntry) {
+	ma, ok1 := a.(map[string]interface{})
+	mb, ok2 := b.(map[string]interface{})
+	if !ok1 || !ok2 {
+		if !reflect.DeepEqual(a, b) {
+			*diffs = append(*diffs, newDiffEntry(prefix, DiffChanged, a, b))
+		}
+		return
+	}
+
+	for key, va := range ma {
+		p := joinDiffPath(prefix, key)
+		vb, ok := mb[key]
+		if !ok {
+			*diffs = append(*diffs, newDiffEntry(p, DiffErpOnly, va, nil))
+			continue
+		}
+		diffValues(p, va, vb, diffs)
+	}
+	for key, vb := range mb {
+		if _, ok := ma[key]; !ok {
+			*diffs = append(*diffs, newDiffEntry(joinDiffPath(prefix, key), DiffDeviceOnly, nil, vb))
+		}
+	}
+}
+
+func newDiffEntry(p, kind string, erp, device interface{}) DiffEntry {
+	section := strings.SplitN(p, ".", 2)[0]
+	return DiffEntry{Section: section, Path: p, Kind: kind, Erp: erp, Device: device}
+}
+
+func joinDiffPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func diffValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+// compareConfig 读取设备上的配置，与ERP平台或主机上的配置对比
+func compareConfig(v IFirmwareVersion, flashTool IFlashTool, ver, devSN, remotePath string, fromErp bool) (*ConfigCompare, error) {
+	var erp []byte
+	var err error
+	if fromErp {
+		erp, _, err = v.FetchConfig(devSN)
+	} else {
+		erp, err = ReadSetting(ver, devSN)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取ERP配置失败: %v", err)
+	}
+
+	output, err := flashTool.RunQuietCommand("cat " + remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取设备配置`%s`失败: %v", remotePath, err)
+	}
+
+	diffs, err := DiffJSON(erp, []byte(output))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConfigCompare{Erp: erp, Device: []byte(output), Diffs: diffs}, nil
+}
+
+// pushConfig 将配置上传到设备，原配置备份为 .bak，然后重启服务使配置生效
+func pushConfig(flashTool IFlashTool, data []byte, remotePath, restartCmd string) error {
+	if _, err := flashTool.RunAndWaitCommand(fmt.Sprintf("mkdir -p %s && (test ! -f %s || cp -f %s %s.bak)", path.Dir(remotePath), remotePath, remotePath, remotePath)); err != nil {
+		return err
+	}
+	if err := flashTool.UploadContent(data, remotePath); err != nil {
+		return err
+	}
+	_, err := flashTool.RunAndWaitCommand(restartCmd)
+	return err
+}
+
+// CompareConfig 对比设备上运行的配置与ERP平台配置，fromErp为false时使用主机上已下载的配置
+func (v *V3) CompareConfig(devSN string, fromErp bool) (*ConfigCompare, error) {
+	return compareConfig(v, v, "v3", devSN, v3RemoteSetting, fromErp)
+}
+
+// PushConfig 将配置推送到设备并重启服务
+func (v *V3) PushConfig(devSN string, data []byte) error {
+	return pushConfig(v, data, v3RemoteSetting, "systemctl restart cgKeepalive")
+}
+
+// SaveDeviceConfig 将设备上的配置保存到主机
+func (v *V3) SaveDeviceConfig(devSN string, data []byte) error {
+	if _, err := ParseV3Setting(data); err != nil {
+		return err
+	}
+	if err := WriteSetting("v3", devSN, data); err != nil {
+		return err
+	}
+	return WriteSettingMeta("v3", devSN, &SettingMeta{Source: SettingSourceDevice})
+}
+
+// CompareConfig 对比设备上运行的配置与ERP平台配置，fromErp为false时使用主机上已下载的配置
+func (v *V2) CompareConfig(devSN string, fromErp bool) (*ConfigCompare, error) {
+	return compareConfig(v, v, "v2", devSN, v2RemoteSetting, fromErp)
+}
+
+// PushConfig 将配置推送到设备并重启服务
+func (v *V2) PushConfig(devSN string, data []byte) error {
+	return pushConfig(v, data, v2RemoteSetting, "systemctl restart cgCollector cgUpdater")
+}
+
+// SaveDeviceConfig 将设备上的配置保存到主机
+func (v *V2) SaveDeviceConfig(devSN string, data []byte) error {
+	if _, err := ParseV2Setting(data); err != nil {
+		return err
+	}
+	if err := WriteSetting("v2", devSN, data); err != nil {
+		return err
+	}
+	return WriteSettingMeta("v2", devSN, &SettingMeta{Source: SettingSourceDevice})
+}
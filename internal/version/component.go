@@ -0,0 +1,426 @@
+package version
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// componentConfigDir 组件配置覆盖目录
+// <ver>/project/<name>.yaml 为项目覆盖，<ver>/device/<sn>.yaml 为设备覆盖
+// 覆盖文件以组件配置文件名为键，如:
+//
+//	project: default        # 仅设备覆盖，指定使用的项目覆盖，默认为default
+//	frpc: default           # 使用的frpc代理模板(templates/frpc/<name>.toml)，设备覆盖优先
+//	cgManager.yaml:
+//	  log_setting:
+//	    level: "info"
+const componentConfigDir = "templates/config"
+
+const defaultProject = "default"
+
+// componentInstallDirs 各版本的安装目录，组件配置位于其下的config目录
+var componentInstallDirs = map[string]string{
+	"v2": "v2_install",
+	"v3": "v3_install",
+}
+
+// ComponentConfigs 渲染后的组件配置
+type ComponentConfigs struct {
+	Files    map[string][]byte // 配置文件名 -> 内容
+	Frpc     []byte            // frpc.toml内容
+	Sources  []string          // 使用的覆盖文件
+	Warnings []string          // 不影响使用的问题，如覆盖了原配置中不存在的字段
+}
+
+// TarOverrides 打包固件时替换的文件，键为打包路径
+func (c *ComponentConfigs) TarOverrides(ver string) map[string][]byte {
+	overrides := make(map[string][]byte, len(c.Files))
+	for name, content := range c.Files {
+		overrides[fmt.Sprintf("%s/config/%s", componentInstallDirs[ver], name)] = content
+	}
+	overrides[fmt.Sprintf("%s/frpc.toml", filepath.ToSlash(localFrpcDir))] = c.Frpc
+	return overrides
+}
+
+// RenderComponentConfigs 以安装目录中的组件配置为模板，依次应用设备固定值、项目覆盖和设备覆盖，并校验结果
+func RenderComponentConfigs(ver, sn string) (*ComponentConfigs, error) {
+	configDir := filepath.Join(componentInstallDirs[ver], "config")
+	entries, err := os.ReadDir(configDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ComponentConfigs{Files: make(map[string][]byte)}
+	docs := make(map[string]*yaml.Node)
+	for _, entry := range entries {
+		if entry.IsDir() || (filepath.Ext(entry.Name()) != ".yaml" && filepath.Ext(entry.Name()) != ".yml") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(configDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("解析`%s`失败: %v", entry.Name(), err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		docs[entry.Name()] = doc.Content[0]
+	}
+
+	// 设备固定值，与原安装脚本写入的内容一致
+	if ver == "v3" {
+		if doc, ok := docs["cgManager.yaml"]; ok {
+			var values yaml.Node
+			if err := values.Encode(map[string]interface{}{
+				"basic_setting": map[string]string{"device_type": "EM500", "device_sn": sn},
+			}); err != nil {
+				return nil, err
+			}
+			mergeYAMLNode(doc, &values, "", nil)
+		}
+	}
+
+	// 设备覆盖中可以指定项目
+	deviceOverrides, err := readComponentOverrides(filepath.Join(componentConfigDir, ver, "device", sn+".yaml"))
+	if err != nil {
+		return nil, err
+	}
+	project := defaultProject
+	if deviceOverrides != nil && deviceOverrides.project != "" {
+		project = deviceOverrides.project
+	}
+	projectPath := filepath.Join(componentConfigDir, ver, "project", project+".yaml")
+	projectOverrides, err := readComponentOverrides(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if projectOverrides == nil && project != defaultProject {
+		return nil, fmt.Errorf("未找到项目覆盖配置: %s", projectPath)
+	}
+
+	frpcProfile := defaultFrpcProfile
+	for _, overrides := range []*componentOverrides{projectOverrides, deviceOverrides} {
+		if overrides == nil {
+			continue
+		}
+		result.Sources = append(result.Sources, overrides.path)
+		if overrides.frpc != "" {
+			frpcProfile = overrides.frpc
+		}
+		for name, values := range overrides.files {
+			doc, ok := docs[name]
+			if !ok {
+				return nil, fmt.Errorf("`%s`中覆盖的组件配置`%s`不存在", overrides.path, name)
+			}
+			mergeYAMLNode(doc, values, "", func(field string) {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("`%s`中覆盖的字段`%s.%s`在原配置中不存在", overrides.path, name, field))
+			})
+		}
+	}
+
+	var errs []string
+	names := make([]string, 0, len(docs))
+	for name := range docs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		doc := docs[name]
+		for _, err := range validateComponentConfig(name, doc) {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return nil, fmt.Errorf("生成`%s`失败: %v", name, err)
+		}
+		encoder.Close()
+		result.Files[name] = buf.Bytes()
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("组件配置校验失败: %s", strings.Join(errs, "; "))
+	}
+
+	if result.Frpc, err = RenderFrpcConfig(frpcProfile, sn); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// renderComponentConfigs 渲染组件配置并输出使用的覆盖文件和提示
+func renderComponentConfigs(flashTool IFlashTool, ver, sn string) (*ComponentConfigs, error) {
+	configs, err := RenderComponentConfigs(ver, sn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, source := range configs.Sources {
+		flashTool.AppendOutput("使用组件配置覆盖: " + source)
+	}
+	for _, warning := range configs.Warnings {
+		flashTool.AppendOutput("组件配置提示: " + warning)
+	}
+	return configs, nil
+}
+
+// componentOverrides 覆盖文件内容
+type componentOverrides struct {
+	path    string
+	project string                // 项目名称，仅设备覆盖
+	frpc    string                // frpc代理模板名称
+	files   map[string]*yaml.Node // 配置文件名 -> 覆盖的字段
+}
+
+// readComponentOverrides 读取覆盖文件，文件不存在时返回nil
+func readComponentOverrides(path string) (*componentOverrides, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("解析`%s`失败: %v", path, err)
+	}
+
+	overrides := &componentOverrides{path: path, files: make(map[string]*yaml.Node)}
+	if len(doc.Content) == 0 {
+		return overrides, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("`%s`格式错误，应为以组件配置文件名为键的映射", path)
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch {
+		case key.Value == "project":
+			overrides.project = value.Value
+		case key.Value == "frpc":
+			overrides.frpc = value.Value
+		case value.Kind != yaml.MappingNode:
+			return nil, fmt.Errorf("`%s`中`%s`的内容应为映射", path, key.Value)
+		default:
+			overrides.files[key.Value] = value
+		}
+	}
+
+	return overrides, nil
+}
+
+// mergeYAMLNode 将src中的字段合并到dst，映射逐层合并，其他值整体替换并沿用原值的格式和注释
+// missing 在覆盖了dst中不存在的字段时调用
+func mergeYAMLNode(dst, src *yaml.Node, prefix string, missing func(field string)) {
+	if src.Kind == yaml.DocumentNode && len(src.Content) > 0 {
+		src = src.Content[0]
+	}
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		field := key.Value
+		if prefix != "" {
+			field = prefix + "." + key.Value
+		}
+
+		idx := -1
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value == key.Value {
+				idx = j + 1
+				break
+			}
+		}
+		if idx < 0 {
+			if missing != nil {
+				missing(field)
+			}
+			dst.Content = append(dst.Content, key, value)
+			continue
+		}
+
+		existing := dst.Content[idx]
+		if existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			mergeYAMLNode(existing, value, field, missing)
+			continue
+		}
+
+		if existing.Kind == value.Kind && existing.Tag == value.Tag {
+			value.Style = existing.Style
+		}
+		if value.LineComment == "" {
+			value.LineComment = existing.LineComment
+		}
+		if value.HeadComment == "" {
+			value.HeadComment = existing.HeadComment
+		}
+		dst.Content[idx] = value
+	}
+}
+
+var (
+	deviceTypes    = []string{"SERVER", "WG800", "EM500", "TCU1200"}
+	zapLevels      = []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
+	gfLevels       = []string{"all", "dev", "prod", "debug", "info", "notice", "warn", "warning", "error", "critical"}
+	rotateSizeExpr = regexp.MustCompile(`(?i)^\d+(\.\d+)?\s*(b|k|kb|m|mb|g|gb|t|tb)?$`)
+)
+
+// componentRule 组件配置字段的校验规则，字段不存在时不校验
+type componentRule struct {
+	path  string
+	check func(value interface{}) error
+}
+
+var componentRules = map[string][]componentRule{
+	"cgManager.yaml": {
+		{"basic_setting.device_type", oneOf(deviceTypes)},
+		{"basic_setting.device_sn", nonEmpty},
+		{"logger.level", oneOf(gfLevels)},
+		{"logger.rotateSize", rotateSize},
+		{"log_setting.level", oneOf(zapLevels)},
+		{"log_setting.max_size", positiveInt},
+		{"log_setting.max_age", positiveInt},
+		{"log_setting.max_backups", positiveInt},
+		{"server_setting.online_config_address", httpURL},
+		{"server_setting.subscribe_topics.erp", topicList},
+		{"server_setting.subscribe_topics.pro", topicList},
+		{"server_setting.channel_topics", topicList},
+		{"server_setting.connectivity_setting.tcp.port", portNumber},
+	},
+	"cgCollector.yaml": {
+		{"logger.level", oneOf(gfLevels)},
+		{"logger.rotateSize", rotateSize},
+		{"database.logger.level", oneOf(gfLevels)},
+		{"database.logger.rotateSize", rotateSize},
+		{"run.getInitUrl", httpURL},
+		{"mqtt.client.port", portNumber},
+		{"mqttTransponder.port", portNumber},
+		{"tcp.port", portNumber},
+		{"collector_grpc.port", portNumber},
+	},
+}
+
+// validateComponentConfig 校验组件配置的YAML结构和已知字段
+func validateComponentConfig(name string, doc *yaml.Node) []error {
+	var content map[string]interface{}
+	if err := doc.Decode(&content); err != nil {
+		return []error{fmt.Errorf("YAML格式错误: %v", err)}
+	}
+
+	var errs []error
+	for _, rule := range componentRules[name] {
+		value, ok := lookupPath(content, rule.path)
+		if !ok {
+			continue
+		}
+		if err := rule.check(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s %v", rule.path, err))
+		}
+	}
+	return errs
+}
+
+func lookupPath(content map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = content
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func oneOf(options []string) func(value interface{}) error {
+	return func(value interface{}) error {
+		s, ok := value.(string)
+		if ok {
+			for _, option := range options {
+				if s == option {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("`%v` 无效，可选值: %s", value, strings.Join(options, ", "))
+	}
+}
+
+func nonEmpty(value interface{}) error {
+	if s, ok := value.(string); !ok || s == "" {
+		return fmt.Errorf("不能为空")
+	}
+	return nil
+}
+
+func positiveInt(value interface{}) error {
+	if n, ok := value.(int); !ok || n <= 0 {
+		return fmt.Errorf("`%v` 应为正整数", value)
+	}
+	return nil
+}
+
+func portNumber(value interface{}) error {
+	if n, ok := value.(int); !ok || n < 1 || n > 65535 {
+		return fmt.Errorf("`%v` 超出端口范围(1-65535)", value)
+	}
+	return nil
+}
+
+func rotateSize(value interface{}) error {
+	switch v := value.(type) {
+	case int:
+		if v >= 0 {
+			return nil
+		}
+	case string:
+		if rotateSizeExpr.MatchString(v) {
+			return nil
+		}
+	}
+	return fmt.Errorf("`%v` 不是有效的文件大小，如 100M", value)
+}
+
+func httpURL(value interface{}) error {
+	s, _ := value.(string)
+	if u, err := url.Parse(s); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("`%v` 不是有效的http(s)地址", value)
+	}
+	return nil
+}
+
+// topicList 订阅主题列表，每个主题需包含%s用于替换SN或通道注册码
+func topicList(value interface{}) error {
+	list, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("应为主题列表")
+	}
+	for _, item := range list {
+		topic, ok := item.(string)
+		if !ok || !strings.Contains(topic, "%s") {
+			return fmt.Errorf("主题 `%v` 应包含 %%s", item)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package version
+
+import (
+	"bytes"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"testing"
+)
+
+func TestMergeYAMLNode(t *testing.T) {
+	const base = `# 基础配置
+basic_setting:
+  device_type: "SERVER" # 网关型号
+  device_sn: ""
+log:
+  level: info
+  rotate_size: 10M
+topics:
+  - data
+`
+	tests := []struct {
+		name        string
+		override    string
+		want        string
+		wantMissing []string
+	}{
+		{
+			name:     "逐层合并映射",
+			override: "basic_setting:\n  device_sn: KBD0921000129\n",
+			want: `# 基础配置
+basic_setting:
+  device_type: "SERVER" # 网关型号
+  device_sn: "KBD0921000129"
+log:
+  level: info
+  rotate_size: 10M
+topics:
+  - data
+`,
+		},
+		{
+			name:     "替换值时保留注释和引号",
+			override: "basic_setting:\n  device_type: EM500\nlog:\n  level: debug\n",
+			want: `# 基础配置
+basic_setting:
+  device_type: "EM500" # 网关型号
+  device_sn: ""
+log:
+  level: debug
+  rotate_size: 10M
+topics:
+  - data
+`,
+		},
+		{
+			name:     "列表整体替换",
+			override: "topics:\n  - data\n  - alarm\n",
+			want: `# 基础配置
+basic_setting:
+  device_type: "SERVER" # 网关型号
+  device_sn: ""
+log:
+  level: info
+  rotate_size: 10M
+topics:
+  - data
+  - alarm
+`,
+		},
+		{
+			name:     "映射替换为标量",
+			override: "log: off\n",
+			want: `# 基础配置
+basic_setting:
+  device_type: "SERVER" # 网关型号
+  device_sn: ""
+log: off
+topics:
+  - data
+`,
+		},
+		{
+			name:     "不存在的字段追加并提示",
+			override: "log:\n  max_age: 7\nonline_config_address: http://1.2.3.4\n",
+			want: `# 基础配置
+basic_setting:
+  device_type: "SERVER" # 网关型号
+  device_sn: ""
+log:
+  level: info
+  rotate_size: 10M
+  max_age: 7
+topics:
+  - data
+online_config_address: http://1.2.3.4
+`,
+			wantMissing: []string{"log.max_age", "online_config_address"},
+		},
+		{
+			name:     "覆盖不是映射时忽略",
+			override: "- a\n- b\n",
+			want:     base,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc, override yaml.Node
+			if err := yaml.Unmarshal([]byte(base), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal([]byte(tt.override), &override); err != nil {
+				t.Fatal(err)
+			}
+
+			var missing []string
+			mergeYAMLNode(doc.Content[0], &override, "", func(field string) {
+				missing = append(missing, field)
+			})
+
+			var buf bytes.Buffer
+			encoder := yaml.NewEncoder(&buf)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(&doc); err != nil {
+				t.Fatal(err)
+			}
+			encoder.Close()
+			if got := buf.String(); got != tt.want {
+				t.Errorf("合并结果:\n%s\n期望:\n%s", got, tt.want)
+			}
+			if !reflect.DeepEqual(missing, tt.wantMissing) {
+				t.Errorf("缺少的字段 = %v, 期望 %v", missing, tt.wantMissing)
+			}
+		})
+	}
+}
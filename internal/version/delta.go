@@ -0,0 +1,58 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// componentPattern 组件文件名，如 cgManager_main_25011701_app、cgService_25011701_parent
+var componentPattern = regexp.MustCompile(`^(.+)_(\d+)_(app|parent)$`)
+
+// ParseComponentFile 解析组件文件名，返回组件名及版本号
+func ParseComponentFile(name string) (component string, version int, ok bool) {
+	matches := componentPattern.FindStringSubmatch(name)
+	if len(matches) != 4 {
+		return "", 0, false
+	}
+	if _, err := fmt.Sscanf(matches[2], "%d", &version); err != nil {
+		return "", 0, false
+	}
+	return matches[1] + "_" + matches[3], version, true
+}
+
+// DeltaUpdate 差分更新，只上传设备已安装版本与本地新版本之间的补丁
+func (v *V3) DeltaUpdate(devSN string) {
+	runFlashTask(v, v.window, &v.flashStatus, devSN, "确认差分更新", fmt.Sprintf("您确定要差分更新 %s 吗？", devSN), "差分更新", func() error {
+		v.AppendOutput("开始差分更新v3程序，执行过程请勿关闭程序!")
+		return v.deltaUpdate()
+	})
+}
+
+func (v *V3) deltaUpdate() error {
+	remoteBin := v3RootDir + "/bin"
+	if _, err := v.RunQuietCommand("test -d " + remoteBin); err != nil {
+		return fmt.Errorf("设备上未安装v3程序，请先完整刷写")
+	}
+
+	s := newFileSyncer(v, v.ArchiveDir, v.DownloadTempDir)
+	if err := s.begin(); err != nil {
+		return err
+	}
+	defer s.end()
+
+	if err := s.syncDir(v.LocalDir, remoteBin); err != nil {
+		return err
+	}
+
+	if s.total() == 0 {
+		v.AppendOutput("设备程序已是最新版本，无需更新!")
+		return nil
+	}
+
+	if _, err := v.RunAndWaitCommand(fmt.Sprintf("chmod -R 755 %s && systemctl restart cgKeepalive", remoteBin)); err != nil {
+		return err
+	}
+
+	v.AppendOutput(fmt.Sprintf("已更新 %d 个程序文件", s.total()))
+	return nil
+}
@@ -0,0 +1,51 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotRegistered 设备未在ERP平台注册或未配置
+var ErrNotRegistered = errors.New("设备未在ERP平台注册")
+
+// HTTPError 请求返回非200响应码
+type HTTPError struct {
+	StatusCode int
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("响应码：%v", e.StatusCode)
+}
+
+// DecryptError 配置解密失败
+type DecryptError struct {
+	Err error
+}
+
+func (e *DecryptError) Error() string {
+	return fmt.Sprintf("配置解密失败: %v", e.Err)
+}
+
+func (e *DecryptError) Unwrap() error {
+	return e.Err
+}
+
+// ChecksumError 配置内容与服务器提供的MD5不一致
+type ChecksumError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("配置MD5校验失败: 服务器提供 %s，实际为 %s", e.Expected, e.Actual)
+}
+
+// joinErrors 将多个错误合并为一条信息
+func joinErrors(errs []error) string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,143 @@
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const settingPolicyFile = "policy.json" // 配置有效期策略，位于配置目录下
+
+const defaultSettingMaxAgeDays = 7
+
+// SettingPolicy 刷写前检查主机上设备配置的策略
+type SettingPolicy struct {
+	MaxAgeDays int  `json:"max_age_days"` // 配置有效期(天)，0表示不检查
+	Block      bool `json:"block"`        // 配置缺失或过期时禁止刷写，否则只提示
+}
+
+// LoadSettingPolicy 读取配置有效期策略，不存在或无法解析时使用默认值
+func LoadSettingPolicy() SettingPolicy {
+	policy := SettingPolicy{MaxAgeDays: defaultSettingMaxAgeDays}
+	data, err := os.ReadFile(filepath.Join(settingDir, settingPolicyFile))
+	if err != nil {
+		return policy
+	}
+	if err := json.Unmarshal(data, &policy); err != nil || policy.MaxAgeDays < 0 {
+		return SettingPolicy{MaxAgeDays: defaultSettingMaxAgeDays}
+	}
+	return policy
+}
+
+// SaveSettingPolicy 保存配置有效期策略
+func SaveSettingPolicy(policy SettingPolicy) error {
+	if policy.MaxAgeDays < 0 {
+		return fmt.Errorf("配置有效期不能小于0天")
+	}
+
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(settingDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(settingDir, settingPolicyFile), data, 0644)
+}
+
+// 主机上设备配置的新旧状态
+const (
+	SettingFresh   = "有效"
+	SettingStale   = "已过期"
+	SettingMissing = "不存在"
+)
+
+// SettingFreshness 主机上设备配置的获取时间及新旧状态
+type SettingFreshness struct {
+	Status    string
+	FetchedAt time.Time
+	Age       time.Duration
+	Meta      *SettingMeta // 没有来源信息时为nil
+}
+
+// Blocked 按策略是否应禁止刷写
+func (f *SettingFreshness) Blocked(policy SettingPolicy) bool {
+	return policy.Block && f.Status != SettingFresh
+}
+
+func (f *SettingFreshness) String() string {
+	if f.Status == SettingMissing {
+		return "主机上没有初始配置"
+	}
+
+	source := "来源未知"
+	if f.Meta != nil {
+		source = f.Meta.Describe()
+	}
+	return fmt.Sprintf("配置获取于 %s(%s前，%s)，状态: %s", f.FetchedAt.Format("2006-01-02 15:04"), formatAge(f.Age), source, f.Status)
+}
+
+// CheckSettingFreshness 检查主机上设备配置是否存在，以及获取时间是否超过有效期
+// 获取时间取自来源信息，没有来源信息的旧配置使用文件修改时间
+func CheckSettingFreshness(ver, sn string, policy SettingPolicy) (*SettingFreshness, error) {
+	info, err := os.Stat(SettingPath(ver, sn))
+	if os.IsNotExist(err) {
+		return &SettingFreshness{Status: SettingMissing}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	freshness := &SettingFreshness{Status: SettingFresh, FetchedAt: info.ModTime()}
+	meta, err := ReadSettingMeta(ver, sn)
+	if err != nil {
+		return nil, err
+	}
+	if meta != nil {
+		freshness.Meta = meta
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", meta.CreatedAt, time.Local); err == nil {
+			freshness.FetchedAt = t
+		}
+	}
+
+	freshness.Age = time.Since(freshness.FetchedAt)
+	if policy.MaxAgeDays > 0 && freshness.Age > time.Duration(policy.MaxAgeDays)*24*time.Hour {
+		freshness.Status = SettingStale
+	}
+	return freshness, nil
+}
+
+// checkSettingBeforeFlash 刷写前检查设备配置，按策略提示或中止刷写
+func checkSettingBeforeFlash(flashTool IFlashTool, ver, sn string) error {
+	policy := LoadSettingPolicy()
+	freshness, err := CheckSettingFreshness(ver, sn, policy)
+	if err != nil {
+		return fmt.Errorf("检查初始配置失败: %v", err)
+	}
+
+	if freshness.Blocked(policy) {
+		return fmt.Errorf("设备 %s: %s，请重新下载初始配置后再刷写", sn, freshness)
+	}
+	switch freshness.Status {
+	case SettingMissing:
+		flashTool.AppendOutput(fmt.Sprintf("警告: 设备 %s: %s，刷写后需设备联网自动获取", sn, freshness))
+	case SettingStale:
+		flashTool.AppendOutput(fmt.Sprintf("警告: 设备 %s: %s，超过有效期 %d 天，ERP平台配置可能已更新", sn, freshness, policy.MaxAgeDays))
+	default:
+		flashTool.AppendOutput(fmt.Sprintf("设备 %s: %s", sn, freshness))
+	}
+	return nil
+}
+
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%d分钟", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%d小时", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%d天", int(age.Hours()/24))
+	}
+}
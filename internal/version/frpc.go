@@ -0,0 +1,160 @@
+package version
+
+import (
+	"EMInit/pkg/utils"
+	"bytes"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// frpcTemplateDir frpc代理模板目录，每个模板一个 <name>.toml
+// 模板中可使用 {{.SN}}，代理名称需包含SN以保证在frps上唯一，SSH代理名称必须为SN(核对设备SN时读取)
+const frpcTemplateDir = "templates/frpc"
+
+const defaultFrpcProfile = "default"
+
+// frpcConfig 用于校验的frpc配置字段
+type frpcConfig struct {
+	ServerAddr string `toml:"serverAddr"`
+	ServerPort int    `toml:"serverPort"`
+	Auth       struct {
+		Method string `toml:"method"`
+		Token  string `toml:"token"`
+	} `toml:"auth"`
+	Proxies []frpcProxy `toml:"proxies"`
+}
+
+type frpcProxy struct {
+	Name          string   `toml:"name"`
+	Type          string   `toml:"type"`
+	LocalIP       string   `toml:"localIP"`
+	LocalPort     int      `toml:"localPort"`
+	RemotePort    int      `toml:"remotePort"`
+	CustomDomains []string `toml:"customDomains"`
+	Subdomain     string   `toml:"subdomain"`
+	SecretKey     string   `toml:"secretKey"`
+}
+
+var frpcProxyTypes = []string{"tcp", "udp", "http", "https", "tcpmux", "stcp", "sudp", "xtcp"}
+
+// frpcSecretProxyTypes 需要配置secretKey的代理类型
+var frpcSecretProxyTypes = []string{"stcp", "sudp", "xtcp"}
+
+// frpcPlaceholder 模板中待替换的密钥占位符
+const frpcPlaceholder = "CHANGE_ME"
+
+// RenderFrpcConfig 使用设备SN渲染frpc代理模板，并校验生成的配置
+func RenderFrpcConfig(profile, sn string) ([]byte, error) {
+	if profile == "" {
+		profile = defaultFrpcProfile
+	}
+
+	tmplPath := filepath.Join(frpcTemplateDir, profile+".toml")
+	tmpl, err := template.New(filepath.Base(tmplPath)).Option("missingkey=error").ParseFiles(tmplPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取frpc模板失败: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, struct{ SN string }{SN: sn}); err != nil {
+		return nil, fmt.Errorf("渲染frpc模板失败: %v", err)
+	}
+
+	if errs := ValidateFrpcConfig(buf.Bytes(), sn); len(errs) > 0 {
+		msgs := make([]string, 0, len(errs))
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return nil, fmt.Errorf("frpc配置(模板: %s)校验失败: %s", profile, strings.Join(msgs, "; "))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ValidateFrpcConfig 校验frpc配置的TOML格式、服务器地址、认证信息及代理
+func ValidateFrpcConfig(data []byte, sn string) []error {
+	var config frpcConfig
+	if _, err := toml.Decode(string(data), &config); err != nil {
+		return []error{fmt.Errorf("TOML格式错误: %v", err)}
+	}
+
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if config.ServerAddr == "" {
+		add("serverAddr 不能为空")
+	} else if !validHost(config.ServerAddr) {
+		add("serverAddr `%s` 不是有效的IP地址或域名", config.ServerAddr)
+	}
+	if config.ServerPort < 1 || config.ServerPort > 65535 {
+		add("serverPort `%d` 超出端口范围(1-65535)", config.ServerPort)
+	}
+	switch config.Auth.Method {
+	case "", "token":
+		if config.Auth.Method == "token" && config.Auth.Token == "" {
+			add("auth.token 不能为空")
+		} else if config.Auth.Token == frpcPlaceholder {
+			add("auth.token 仍为模板占位符 %s，请替换为frps的token", frpcPlaceholder)
+		}
+	case "oidc":
+	default:
+		add("auth.method `%s` 无效，可选值: token, oidc", config.Auth.Method)
+	}
+
+	if len(config.Proxies) == 0 {
+		add("至少需要一个代理")
+	}
+	names := make(map[string]bool)
+	hasSSH := false
+	for i, proxy := range config.Proxies {
+		label := fmt.Sprintf("proxies[%d]", i)
+		if proxy.Name == "" {
+			add("%s.name 不能为空", label)
+		} else {
+			label = fmt.Sprintf("代理`%s`", proxy.Name)
+			if names[proxy.Name] {
+				add("%s 名称重复", label)
+			}
+			names[proxy.Name] = true
+			if sn != "" && !strings.Contains(proxy.Name, sn) {
+				add("%s 名称需包含设备SN以保证唯一", label)
+			}
+		}
+
+		if !utils.ContainsString(frpcProxyTypes, proxy.Type) {
+			add("%s type `%s` 无效，可选值: %s", label, proxy.Type, strings.Join(frpcProxyTypes, ", "))
+		}
+		if proxy.LocalPort < 1 || proxy.LocalPort > 65535 {
+			add("%s localPort `%d` 超出端口范围(1-65535)", label, proxy.LocalPort)
+		}
+		if proxy.RemotePort < 0 || proxy.RemotePort > 65535 {
+			add("%s remotePort `%d` 超出端口范围(0-65535)", label, proxy.RemotePort)
+		}
+		if (proxy.Type == "http" || proxy.Type == "https") && len(proxy.CustomDomains) == 0 && proxy.Subdomain == "" {
+			add("%s 需配置 customDomains 或 subdomain", label)
+		}
+		if utils.ContainsString(frpcSecretProxyTypes, proxy.Type) {
+			if proxy.SecretKey == "" {
+				add("%s 类型为%s，secretKey 不能为空", label, proxy.Type)
+			} else if proxy.SecretKey == frpcPlaceholder {
+				add("%s secretKey 仍为模板占位符 %s，请替换", label, frpcPlaceholder)
+			}
+		}
+		if proxy.Type == "tcp" && proxy.LocalPort == 22 {
+			hasSSH = true
+			if sn != "" && proxy.Name != sn {
+				add("%s 为SSH代理，名称必须为设备SN `%s`", label, sn)
+			}
+		}
+	}
+	if len(config.Proxies) > 0 && !hasSSH {
+		add("缺少SSH代理(type = \"tcp\", localPort = 22)")
+	}
+
+	return errs
+}
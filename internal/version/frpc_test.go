@@ -0,0 +1,56 @@
+package version
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateFrpcConfig(t *testing.T) {
+	const sn = "2C2021090001"
+	const server = "serverAddr = \"frps.2cifang.cn\"\nserverPort = 7000\n"
+	const ssh = "\n[[proxies]]\nname = \"2C2021090001\"\ntype = \"tcp\"\nlocalIP = \"127.0.0.1\"\nlocalPort = 22\n"
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string // 为空时应校验通过
+	}{
+		{"默认配置", server + ssh, ""},
+		{"TOML格式错误", "serverAddr = ", "TOML格式错误"},
+		{"缺少服务器地址", "serverPort = 7000\n" + ssh, "serverAddr 不能为空"},
+		{"无效的服务器地址", "serverAddr = \"frps..cn\"\nserverPort = 7000\n" + ssh, "不是有效的IP地址或域名"},
+		{"端口超出范围", "serverAddr = \"1.2.3.4\"\nserverPort = 70000\n" + ssh, "serverPort `70000` 超出端口范围"},
+		{"空token", server + "[auth]\nmethod = \"token\"\ntoken = \"\"\n" + ssh, "auth.token 不能为空"},
+		{"token占位符", server + "[auth]\nmethod = \"token\"\ntoken = \"CHANGE_ME\"\n" + ssh, "auth.token 仍为模板占位符"},
+		{"有效token", server + "[auth]\nmethod = \"token\"\ntoken = \"x9Rk2\"\n" + ssh, ""},
+		{"无效认证方式", server + "[auth]\nmethod = \"password\"\n" + ssh, "auth.method `password` 无效"},
+		{"没有代理", server, "至少需要一个代理"},
+		{"缺少SSH代理", server + "\n[[proxies]]\nname = \"2C2021090001_web\"\ntype = \"http\"\nlocalPort = 80\nsubdomain = \"2C2021090001\"\n", "缺少SSH代理"},
+		{"SSH代理名称不是SN", server + strings.Replace(ssh, `"2C2021090001"`, `"ssh_2C2021090001"`, 1), "名称必须为设备SN"},
+		{"代理名称不含SN", server + ssh + "\n[[proxies]]\nname = \"web\"\ntype = \"http\"\nlocalPort = 80\nsubdomain = \"gw\"\n", "名称需包含设备SN"},
+		{"代理名称重复", server + ssh + ssh, "名称重复"},
+		{"无效代理类型", server + ssh + "\n[[proxies]]\nname = \"2C2021090001_x\"\ntype = \"ftp\"\nlocalPort = 21\n", "type `ftp` 无效"},
+		{"本地端口超出范围", server + ssh + "\n[[proxies]]\nname = \"2C2021090001_x\"\ntype = \"tcp\"\nlocalPort = 0\n", "localPort `0` 超出端口范围"},
+		{"http缺少域名", server + ssh + "\n[[proxies]]\nname = \"2C2021090001_web\"\ntype = \"http\"\nlocalPort = 80\n", "需配置 customDomains 或 subdomain"},
+		{"stcp缺少secretKey", server + ssh + "\n[[proxies]]\nname = \"2C2021090001_modbus\"\ntype = \"stcp\"\nlocalPort = 502\n", "secretKey 不能为空"},
+		{"stcp secretKey占位符", server + ssh + "\n[[proxies]]\nname = \"2C2021090001_modbus\"\ntype = \"stcp\"\nsecretKey = \"CHANGE_ME\"\nlocalPort = 502\n", "secretKey 仍为模板占位符"},
+		{"stcp有效secretKey", server + ssh + "\n[[proxies]]\nname = \"2C2021090001_modbus\"\ntype = \"stcp\"\nsecretKey = \"m0dbus\"\nlocalPort = 502\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateFrpcConfig([]byte(tt.config), sn)
+			if tt.wantErr == "" {
+				if len(errs) > 0 {
+					t.Errorf("应校验通过, 实际: %v", errs)
+				}
+				return
+			}
+			for _, err := range errs {
+				if strings.Contains(err.Error(), tt.wantErr) {
+					return
+				}
+			}
+			t.Errorf("应包含错误 %q, 实际: %v", tt.wantErr, errs)
+		})
+	}
+}
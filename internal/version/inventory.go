@@ -0,0 +1,300 @@
+package version
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const inventoryDir = "inventory" // 主机上设备快照的存放目录
+
+const remoteFrpcConfig = remoteFrpcDir + "/frpc.toml"
+
+// NetworkAddress 设备网口的IPv4地址
+type NetworkAddress struct {
+	Interface string `json:"interface"`
+	Address   string `json:"address"` // CIDR格式，如 192.168.2.136/24
+}
+
+// DeviceInventory 从设备上读取的身份及状态快照
+type DeviceInventory struct {
+	CollectedAt string `json:"collected_at"`
+	Version     string `json:"version"` // 设备上安装的程序版本 v2/v3，未安装为空
+
+	SN        string            `json:"sn"`         // 设备SN，各来源不一致时取cgManager.yaml
+	SNSources map[string]string `json:"sn_sources"` // 各来源读取到的SN，如 cgManager.yaml、frpc.toml、SN标签文件
+
+	Components map[string]int `json:"components"` // 已安装的组件版本，如 cgManager_main_app: 25011701
+
+	SettingSha256 string `json:"setting_sha256,omitempty"` // 设备上配置文件的SHA256，不存在时为空
+
+	Addresses  []NetworkAddress `json:"addresses"`
+	Interfaces string           `json:"interfaces"` // /etc/network/interfaces 内容
+
+	OS     string `json:"os"`
+	Kernel string `json:"kernel"`
+	Uptime int64  `json:"uptime"` // 已运行秒数
+}
+
+// CollectInventory 通过SSH只读查询设备信息，单项读取失败时记录为空并继续
+func CollectInventory(flashTool IFlashTool) (*DeviceInventory, error) {
+	if _, err := flashTool.RunQuietCommand("true"); err != nil {
+		return nil, err
+	}
+
+	inv := &DeviceInventory{
+		CollectedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Components:  make(map[string]int),
+	}
+
+	rootDir, remoteSetting := "", ""
+	if _, err := flashTool.RunQuietCommand(fmt.Sprintf("test -d %s/bin", v3RootDir)); err == nil {
+		inv.Version, rootDir, remoteSetting = "v3", v3RootDir, v3RemoteSetting
+	} else if _, err := flashTool.RunQuietCommand(fmt.Sprintf("test -d %s/bin", v2RootDir)); err == nil {
+		inv.Version, rootDir, remoteSetting = "v2", v2RootDir, v2RemoteSetting
+	}
+
+	// 设备SN
+	check := &SNCheck{Device: CollectDeviceSN(flashTool)}
+	inv.SNSources = check.Device
+	inv.SN = check.DeviceSN()
+
+	if rootDir != "" {
+		// 组件版本: v3从文件名解析，v2读取 <name>.version
+		if output, err := flashTool.RunQuietCommand(fmt.Sprintf("ls %s/bin", rootDir)); err == nil {
+			for _, name := range strings.Fields(output) {
+				if component, ver, ok := ParseComponentFile(name); ok {
+					inv.Components[component] = ver
+				}
+			}
+		}
+		output, err := flashTool.RunQuietCommand(fmt.Sprintf("cd %s && for f in $(find . -maxdepth 2 -name '*.version'); do echo \"$(basename $f .version) $(cat $f)\"; done", rootDir))
+		if err == nil {
+			for _, line := range strings.Split(output, "\n") {
+				fields := strings.Fields(line)
+				if len(fields) != 2 {
+					continue
+				}
+				if ver, err := strconv.Atoi(fields[1]); err == nil {
+					inv.Components[fields[0]] = ver
+				}
+			}
+		}
+
+		if output, err := flashTool.RunQuietCommand("sha256sum " + remoteSetting); err == nil {
+			if fields := strings.Fields(output); len(fields) > 0 {
+				inv.SettingSha256 = fields[0]
+			}
+		}
+	}
+
+	// 网络配置
+	if output, err := flashTool.RunQuietCommand("ip -o -4 addr show"); err == nil {
+		inv.Addresses = parseIPAddresses(output)
+	}
+	if output, err := flashTool.RunQuietCommand("cat /etc/network/interfaces"); err == nil {
+		inv.Interfaces = output
+	}
+
+	// 系统信息
+	if output, err := flashTool.RunQuietCommand(". /etc/os-release && echo \"$PRETTY_NAME\""); err == nil {
+		inv.OS = strings.TrimSpace(output)
+	}
+	if output, err := flashTool.RunQuietCommand("uname -r"); err == nil {
+		inv.Kernel = strings.TrimSpace(output)
+	}
+	if output, err := flashTool.RunQuietCommand("cat /proc/uptime"); err == nil {
+		if fields := strings.Fields(output); len(fields) > 0 {
+			if seconds, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				inv.Uptime = int64(seconds)
+			}
+		}
+	}
+
+	return inv, nil
+}
+
+// parseManagerSN 读取cgManager.yaml中的 basic_setting.device_sn
+func parseManagerSN(data []byte) string {
+	var config struct {
+		BasicSetting struct {
+			DeviceSN string `yaml:"device_sn"`
+		} `yaml:"basic_setting"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return ""
+	}
+	return config.BasicSetting.DeviceSN
+}
+
+// parseFrpcSN 读取frpc.toml中SSH代理的名称，ValidateFrpcConfig要求SSH代理名称即设备SN
+func parseFrpcSN(data []byte) string {
+	var config frpcConfig
+	if _, err := toml.Decode(string(data), &config); err != nil {
+		return ""
+	}
+	for _, proxy := range config.Proxies {
+		if proxy.Type == "tcp" && proxy.LocalPort == 22 {
+			return proxy.Name
+		}
+	}
+	return ""
+}
+
+// parseIPAddresses 解析 ip -o -4 addr show 的输出，忽略回环地址
+func parseIPAddresses(output string) []NetworkAddress {
+	var addresses []NetworkAddress
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[2] != "inet" || fields[1] == "lo" {
+			continue
+		}
+		addresses = append(addresses, NetworkAddress{Interface: fields[1], Address: fields[3]})
+	}
+	return addresses
+}
+
+// Advise 对比主机上的程序及配置，给出设备是否需要重新刷写或更新的建议
+func (inv *DeviceInventory) Advise(sn string) []string {
+	var advice []string
+	if inv.Version == "" {
+		return append(advice, "设备上未安装程序，需要完整刷写")
+	}
+
+	if sn != "" && inv.SN != "" && inv.SN != sn {
+		advice = append(advice, fmt.Sprintf("设备SN `%s` 与输入的SN `%s` 不一致", inv.SN, sn))
+	}
+	for _, mismatch := range (&SNCheck{Device: inv.SNSources}).Mismatches() {
+		advice = append(advice, mismatch+"，建议重新刷写")
+	}
+
+	local := localComponents(inv.Version)
+	names := make([]string, 0, len(local))
+	for name := range local {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		installed, ok := inv.Components[name]
+		switch {
+		case !ok:
+			advice = append(advice, fmt.Sprintf("设备上缺少组件 %s，建议增量同步", name))
+		case installed < local[name]:
+			advice = append(advice, fmt.Sprintf("组件 %s 版本 %d 低于主机版本 %d，建议差分更新或增量同步", name, installed, local[name]))
+		}
+	}
+
+	if sn == "" {
+		sn = inv.SN
+	}
+	if inv.SettingSha256 == "" {
+		advice = append(advice, "设备上没有初始配置文件")
+	} else if data, err := ReadSetting(inv.Version, sn); err == nil {
+		if fmt.Sprintf("%x", sha256.Sum256(data)) != inv.SettingSha256 {
+			advice = append(advice, "设备上的配置与主机上的配置不同，可在“配置对比”中查看差异")
+		}
+	}
+
+	return advice
+}
+
+// localComponents 主机上固件的组件版本
+func localComponents(ver string) map[string]int {
+	components := make(map[string]int)
+	switch ver {
+	case "v3":
+		files, err := os.ReadDir(NewV3(nil, nil).LocalDir)
+		if err != nil {
+			return components
+		}
+		for _, file := range files {
+			if component, version, ok := ParseComponentFile(file.Name()); ok {
+				components[component] = version
+			}
+		}
+	case "v2":
+		v := NewV2(nil, nil)
+		data, err := os.ReadFile(filepath.Join(v.LocalDir, "cgBox.version"))
+		if err != nil {
+			return components
+		}
+		if version, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+			components["cgBox"] = version
+		}
+	}
+	return components
+}
+
+// String 设备快照摘要
+func (inv *DeviceInventory) String() string {
+	var b strings.Builder
+	version := inv.Version
+	if version == "" {
+		version = "未安装"
+	}
+	fmt.Fprintf(&b, "设备SN: %s, 程序版本: %s\n", inv.SN, version)
+
+	names := make([]string, 0, len(inv.Components))
+	for name := range inv.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "  组件 %s: %d\n", name, inv.Components[name])
+	}
+
+	if inv.SettingSha256 != "" {
+		fmt.Fprintf(&b, "配置SHA256: %s\n", inv.SettingSha256)
+	}
+	for _, addr := range inv.Addresses {
+		fmt.Fprintf(&b, "网口 %s: %s\n", addr.Interface, addr.Address)
+	}
+	fmt.Fprintf(&b, "系统: %s, 内核: %s, 已运行: %s", inv.OS, inv.Kernel, formatAge(time.Duration(inv.Uptime)*time.Second))
+	return b.String()
+}
+
+// snDirName 将设备上读取的SN转换为主机上的目录名，SN不合法的字符替换为下划线，避免路径越出目录
+func snDirName(sn string) string {
+	if sn == "" {
+		return "unknown"
+	}
+	if ValidateSN(sn) == nil {
+		return sn
+	}
+	name := []rune(sn)
+	for i, r := range name {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return string(name)
+}
+
+// SaveInventory 保存设备快照到 inventory/<sn>/<时间>.json，返回文件路径
+func SaveInventory(inv *DeviceInventory) (string, error) {
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(inventoryDir, snDirName(inv.SN))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, time.Now().Format("20060102150405")+".json")
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return "", err
+	}
+	return file, nil
+}
@@ -0,0 +1,62 @@
+package version
+
+import (
+	"os"
+	"testing"
+)
+
+// 默认frpc模板渲染后，核对SN时应从SSH代理读到设备SN
+func TestParseFrpcSN(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	sn := "2C2021090001"
+	data, err := RenderFrpcConfig(defaultFrpcProfile, sn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parseFrpcSN(data); got != sn {
+		t.Errorf("parseFrpcSN() = %q, 期望 %q", got, sn)
+	}
+}
+
+// SSH代理名称只包含SN时应校验失败，否则核对SN时会读到错误的SN
+func TestValidateFrpcSSHName(t *testing.T) {
+	data := []byte(`serverAddr = "frps.2cifang.cn"
+serverPort = 7000
+
+[[proxies]]
+name = "ssh_2C2021090001"
+type = "tcp"
+localIP = "127.0.0.1"
+localPort = 22
+`)
+	if errs := ValidateFrpcConfig(data, "2C2021090001"); len(errs) == 0 {
+		t.Error("SSH代理名称不等于SN时应校验失败")
+	}
+}
+
+// 设备上读取的SN用作主机目录名时不能越出目录
+func TestSNDirName(t *testing.T) {
+	tests := []struct {
+		sn   string
+		want string
+	}{
+		{"KBD0921000129", "KBD0921000129"},
+		{"", "unknown"},
+		{"../../etc", "______etc"},
+		{`C:\Windows`, "C__Windows"},
+		{"KBD 0921/x", "KBD_0921_x"},
+	}
+	for _, tt := range tests {
+		if got := snDirName(tt.sn); got != tt.want {
+			t.Errorf("snDirName(%q) = %q, 期望 %q", tt.sn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LogDirs 设备上各版本组件的日志目录
+var LogDirs = []string{v3RootDir + "/data/log", v2RootDir + "/data/log"}
+
+// LogLevels 日志级别，由低到高
+var LogLevels = []string{"DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+var logLevelPattern = regexp.MustCompile(`(?i)\b(debug|info|warn|warning|error|fatal|panic)\b`)
+
+// ListLogFiles 列出设备上的日志文件，包括切分后的旧日志
+func ListLogFiles(flashTool IFlashTool) ([]string, error) {
+	output, err := flashTool.RunQuietCommand(fmt.Sprintf("find %s -maxdepth 1 -type f 2>/dev/null | sort; true", strings.Join(LogDirs, " ")))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// TailLogCommand 读取日志文件最后lines行的命令，follow时持续输出新内容
+// 压缩的旧日志不支持follow
+func TailLogCommand(file string, lines int, follow bool) string {
+	if strings.HasSuffix(file, ".gz") {
+		return fmt.Sprintf("zcat -f '%s' | tail -n %d", file, lines)
+	}
+	if follow {
+		return fmt.Sprintf("tail -n %d -F '%s'", lines, file)
+	}
+	return fmt.Sprintf("tail -n %d '%s'", lines, file)
+}
+
+// LogLineLevel 日志行的级别在LogLevels中的序号，无法识别时返回-1
+func LogLineLevel(line string) int {
+	match := logLevelPattern.FindString(line)
+	switch strings.ToUpper(match) {
+	case "DEBUG":
+		return 0
+	case "INFO":
+		return 1
+	case "WARN", "WARNING":
+		return 2
+	case "ERROR":
+		return 3
+	case "FATAL", "PANIC":
+		return 4
+	}
+	return -1
+}
+
+// MatchLogLine 判断日志行是否包含text(不区分大小写)，且级别不低于minLevel
+// minLevel为空时不按级别过滤
+func MatchLogLine(line, text, minLevel string) bool {
+	if text != "" && !strings.Contains(strings.ToLower(line), strings.ToLower(text)) {
+		return false
+	}
+	if minLevel == "" {
+		return true
+	}
+	for i, level := range LogLevels {
+		if level == minLevel {
+			return LogLineLevel(line) >= i
+		}
+	}
+	return true
+}
@@ -0,0 +1,214 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const migrateBackupDir = "/datas/backup" // 迁移前的备份目录
+
+// v2Services v2程序使用的系统服务
+var v2Services = []string{"cgCollector", "cgUpdater"}
+
+// migrateBackupServices 迁移前备份的服务文件，失败时恢复
+var migrateBackupServices = []string{"cgCollector", "cgUpdater", "frpc"}
+
+// MigrateFromV2 将设备上的v2程序迁移到v3
+func (v *V3) MigrateFromV2(devSN string) {
+	runFlashTask(v, v.window, &v.flashStatus, devSN, "确认迁移", fmt.Sprintf("您确定要将 %s 从v2迁移到v3吗？", devSN), "迁移到v3", func() error {
+		v.AppendOutput("开始将设备从v2迁移到v3，执行过程请勿关闭程序!")
+		return v.migrateFromV2(devSN)
+	})
+}
+
+func (v *V3) migrateFromV2(devSN string) (err error) {
+	// 检测v2程序
+	if _, err = v.RunAndWaitCommand(fmt.Sprintf("test -d %s/bin", v2RootDir)); err != nil {
+		return fmt.Errorf("未检测到设备上的v2程序: %v", err)
+	}
+	v.AppendOutput("检测到设备上的v2程序")
+
+	// 在修改设备前准备好v3配置，并完成刷写前的检查
+	if err = v.prepareMigrationSetting(devSN); err != nil {
+		return err
+	}
+	configs, err := v.prepareFlash(devSN)
+	if err != nil {
+		return err
+	}
+
+	// 备份v2程序、frpc及服务文件
+	backupDir := fmt.Sprintf("%s/v2_%s", migrateBackupDir, time.Now().Format("20060102150405"))
+	if _, err = v.RunAndWaitCommand(fmt.Sprintf("mkdir -p %s && tar -czf %s/cf_go_v2.tar.gz -C /datas cf_go_v2 frpc", backupDir, backupDir)); err != nil {
+		return fmt.Errorf("备份v2程序失败: %v", err)
+	}
+	for _, service := range migrateBackupServices {
+		v.RunAndWaitCommand(fmt.Sprintf("cp -f /etc/systemd/system/%s.service %s/ 2>/dev/null || true", service, backupDir))
+	}
+	v.AppendOutput("v2程序已备份到设备目录: " + backupDir)
+
+	// 之后任一步骤失败都恢复v2程序
+	defer func() {
+		if err != nil {
+			v.AppendOutput(fmt.Sprintf("迁移失败: %v，正在恢复v2程序...", err))
+			if restoreErr := v.restoreV2(backupDir); restoreErr != nil {
+				err = fmt.Errorf("%v; 恢复v2程序失败，备份位于设备目录 %s: %v", err, backupDir, restoreErr)
+				return
+			}
+			v.AppendOutput("v2程序已恢复")
+		}
+	}()
+
+	// 停止并禁用v2服务
+	for _, service := range v2Services {
+		if _, err = v.RunAndWaitCommand(fmt.Sprintf("systemctl stop %s || true; systemctl disable %s || true", service, service)); err != nil {
+			return err
+		}
+		if _, err = v.RunAndWaitCommand(fmt.Sprintf("rm -f /etc/systemd/system/%s.service", service)); err != nil {
+			return err
+		}
+	}
+	if _, err = v.RunAndWaitCommand("systemctl daemon-reload"); err != nil {
+		return err
+	}
+
+	// 安装v3程序
+	if err = v.install(devSN, configs); err != nil {
+		return err
+	}
+
+	// 预演时设备未改动，验证没有意义
+	if isDryRun(v.IFlashTool) {
+		v.AppendOutput("[预演] 跳过迁移验证: 等待服务启动后检查frpc、cgKeepalive服务及v3配置文件")
+		return nil
+	}
+	return v.verifyMigration()
+}
+
+// prepareMigrationSetting 准备v3配置，优先使用已下载的v3配置，否则从v2配置转换
+func (v *V3) prepareMigrationSetting(devSN string) error {
+	if SettingExists("v3", devSN) {
+		v.AppendOutput("使用已下载的v3配置: " + SettingPath("v3", devSN))
+		return nil
+	}
+
+	// 优先使用主机上的v2配置，其次读取设备上的v2配置
+	meta := &SettingMeta{Source: SettingSourceV2, ConvertedFrom: SettingPath("v2", devSN)}
+	data, err := ReadSetting("v2", devSN)
+	if errors.Is(err, ErrSettingLocked) {
+		return err
+	}
+	if err == nil {
+		meta.CreatedAt = hostSettingFetchedAt("v2", devSN)
+	} else {
+		output, err := v.RunQuietCommand("cat " + v2RemoteSetting)
+		if err != nil {
+			return fmt.Errorf("未找到v3配置，且无法读取v2配置: %v", err)
+		}
+		data = []byte(output)
+		meta.ConvertedFrom = "设备 " + v2RemoteSetting
+		if output, err := v.RunQuietCommand("stat -c %Y " + v2RemoteSetting); err == nil {
+			if sec, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64); err == nil {
+				meta.CreatedAt = time.Unix(sec, 0).Format("2006-01-02 15:04:05")
+			}
+		}
+	}
+
+	v2Setting, err := ParseV2Setting(data)
+	if err != nil {
+		return err
+	}
+
+	setting, warnings := ConvertV2Setting(v2Setting)
+	for _, warning := range warnings {
+		v.AppendOutput("配置转换提示: " + warning)
+	}
+
+	content, err := setting.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if isDryRun(v.IFlashTool) {
+		v.AppendOutput(fmt.Sprintf("[预演] 写入主机配置: %s, 大小: %d 字节", SettingPath("v3", devSN), len(content)))
+		return nil
+	}
+	if err := WriteSetting("v3", devSN, content); err != nil {
+		return err
+	}
+	// 记录来源，之后从ERP下载时先备份，有效期按v2配置的获取时间计算
+	if err := WriteSettingMeta("v3", devSN, meta); err != nil {
+		return err
+	}
+
+	v.AppendOutput("v2配置已转换为v3配置: " + SettingPath("v3", devSN))
+	return nil
+}
+
+// verifyMigration 验证v3程序已运行且v2服务已移除
+func (v *V3) verifyMigration() error {
+	v.AppendOutput("等待服务启动...")
+	time.Sleep(10 * time.Second)
+
+	var problems []string
+	for _, service := range []string{"frpc", "cgKeepalive"} {
+		if _, err := v.RunQuietCommand("systemctl is-active " + service); err != nil {
+			problems = append(problems, fmt.Sprintf("服务%s未运行", service))
+		}
+	}
+	for _, service := range v2Services {
+		if _, err := v.RunQuietCommand("systemctl is-enabled " + service); err == nil {
+			problems = append(problems, fmt.Sprintf("v2服务%s仍处于启用状态", service))
+		}
+	}
+	if _, err := v.RunQuietCommand("test -f " + v3RemoteSetting); err != nil {
+		problems = append(problems, "设备上缺少v3配置文件")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("迁移验证失败: %s", strings.Join(problems, "; "))
+	}
+
+	v.AppendOutput("迁移验证通过!")
+	return nil
+}
+
+// restoreV2 从备份恢复v2程序、frpc及服务文件，停止v3服务并重新启用v2服务
+func (v *V3) restoreV2(backupDir string) error {
+	v.RunAndWaitCommand("systemctl stop cgKeepalive frpc || true; systemctl disable cgKeepalive || true")
+	v.RunAndWaitCommand("rm -f /etc/systemd/system/cgKeepalive.service")
+
+	if _, err := v.RunAndWaitCommand(fmt.Sprintf("rm -rf /datas/frpc && tar -xzf %s/cf_go_v2.tar.gz -C /datas", backupDir)); err != nil {
+		return fmt.Errorf("解压v2备份失败: %v", err)
+	}
+	for _, service := range migrateBackupServices {
+		// 迁移前不存在的服务文件不恢复
+		if _, err := v.RunAndWaitCommand(fmt.Sprintf("[ ! -f %s/%s.service ] || cp -f %s/%s.service /etc/systemd/system/", backupDir, service, backupDir, service)); err != nil {
+			return fmt.Errorf("恢复服务文件%s失败: %v", service, err)
+		}
+	}
+	if _, err := v.RunAndWaitCommand("systemctl daemon-reload"); err != nil {
+		return err
+	}
+	for _, service := range migrateBackupServices {
+		if _, err := v.RunAndWaitCommand(fmt.Sprintf("[ ! -f /etc/systemd/system/%s.service ] || (systemctl enable %s && systemctl start %s)", service, service, service)); err != nil {
+			return fmt.Errorf("启动服务%s失败: %v", service, err)
+		}
+	}
+	return nil
+}
+
+// hostSettingFetchedAt 主机上设备配置的获取时间，没有来源信息时使用文件修改时间，都没有时返回空
+func hostSettingFetchedAt(ver, sn string) string {
+	if meta, err := ReadSettingMeta(ver, sn); err == nil && meta != nil && meta.CreatedAt != "" {
+		return meta.CreatedAt
+	}
+	if info, err := os.Stat(SettingPath(ver, sn)); err == nil {
+		return info.ModTime().Format("2006-01-02 15:04:05")
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package version
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeFlashTool 记录执行的命令，命令包含failOn时返回错误
+type fakeFlashTool struct {
+	dryRun   bool
+	failOn   string
+	commands []string
+	output   []string
+}
+
+func (f *fakeFlashTool) RunAndWaitCommand(cmd string) (string, error) {
+	f.commands = append(f.commands, cmd)
+	if f.failOn != "" && strings.Contains(cmd, f.failOn) {
+		return "", errors.New("命令执行失败")
+	}
+	return "", nil
+}
+func (f *fakeFlashTool) AppendOutput(message string)                   { f.output = append(f.output, message) }
+func (f *fakeFlashTool) UploadFile(localPath, remotePath string) error { return nil }
+func (f *fakeFlashTool) RunQuietCommand(cmd string) (string, error)    { return "", nil }
+func (f *fakeFlashTool) StreamToCommand(cmd string, write func(w io.Writer) error) error {
+	return nil
+}
+func (f *fakeFlashTool) StreamFromCommand(cmd string, read func(r io.Reader) error) error {
+	return nil
+}
+func (f *fakeFlashTool) UploadContent(content []byte, remotePath string) error { return nil }
+func (f *fakeFlashTool) DryRun() bool                                          { return f.dryRun }
+
+// ran 是否执行过包含sub的命令
+func (f *fakeFlashTool) ran(sub string) bool {
+	for _, cmd := range f.commands {
+		if strings.Contains(cmd, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// setupMigrationDir 在临时目录中准备v2配置及安装目录、模板，返回使用的SN
+func setupMigrationDir(t *testing.T) string {
+	t.Helper()
+	samples, err := filepath.Glob("../../setting/v2/*.json")
+	if err != nil || len(samples) == 0 {
+		t.Skip("没有v2配置样例")
+	}
+	data, err := os.ReadFile(samples[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for _, dir := range []string{"v3_install", "share", frpcTemplateDir, filepath.Join(componentConfigDir, "v3", "project")} {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join(root, dir), dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sn := "TESTSN"
+	if err := os.MkdirAll(filepath.Join(settingDir, "v2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(SettingPath("v2", sn), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return sn
+}
+
+func TestPrepareMigrationSettingDryRun(t *testing.T) {
+	sn := setupMigrationDir(t)
+
+	flashTool := &fakeFlashTool{dryRun: true}
+	v := NewV3(flashTool, nil)
+	if err := v.prepareMigrationSetting(sn); err != nil {
+		t.Fatalf("prepareMigrationSetting: %v", err)
+	}
+	if SettingExists("v3", sn) {
+		t.Errorf("预演模式不应写入 %s", SettingPath("v3", sn))
+	}
+	if len(flashTool.output) == 0 {
+		t.Error("预演模式应记录将要写入的配置")
+	}
+}
+
+func TestMigrateFromV2CheckFailsBeforeStoppingV2(t *testing.T) {
+	sn := setupMigrationDir(t)
+
+	// 设备覆盖指定了不存在的项目，组件配置渲染失败
+	deviceDir := filepath.Join(componentConfigDir, "v3", "device")
+	if err := os.MkdirAll(deviceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(deviceDir, sn+".yaml"), []byte("project: missing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	flashTool := &fakeFlashTool{}
+	if err := NewV3(flashTool, nil).migrateFromV2(sn); err == nil {
+		t.Fatal("组件配置渲染失败时应返回错误")
+	}
+	if flashTool.ran("systemctl stop") || flashTool.ran("tar -czf") {
+		t.Errorf("检查失败前不应改动设备: %v", flashTool.commands)
+	}
+}
+
+func TestMigrateFromV2RestoresV2OnFailure(t *testing.T) {
+	sn := setupMigrationDir(t)
+
+	flashTool := &fakeFlashTool{failOn: "sh v3_install.sh"}
+	if err := NewV3(flashTool, nil).migrateFromV2(sn); err == nil {
+		t.Fatal("安装失败时应返回错误")
+	}
+	for _, cmd := range []string{"tar -xzf", "cgCollector.service /etc/systemd/system/", "systemctl enable cgCollector", "systemctl enable cgUpdater"} {
+		if !flashTool.ran(cmd) {
+			t.Errorf("安装失败后未执行恢复命令 `%s`", cmd)
+		}
+	}
+}
+
+func TestPrepareMigrationSettingMeta(t *testing.T) {
+	sn := setupMigrationDir(t)
+	fetchedAt := time.Now().Add(-90 * 24 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(SettingPath("v2", sn), fetchedAt, fetchedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewV3(&fakeFlashTool{}, nil).prepareMigrationSetting(sn); err != nil {
+		t.Fatalf("prepareMigrationSetting: %v", err)
+	}
+	meta, err := ReadSettingMeta("v3", sn)
+	if err != nil || meta == nil {
+		t.Fatalf("未写入来源信息: %v", err)
+	}
+	if meta.Source != SettingSourceV2 || !meta.LocallyAuthored() {
+		t.Errorf("来源 = %s, 应为本地的v2转换配置", meta.Source)
+	}
+
+	freshness, err := CheckSettingFreshness("v3", sn, SettingPolicy{MaxAgeDays: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !freshness.FetchedAt.Equal(fetchedAt) || freshness.Status != SettingStale {
+		t.Errorf("获取时间 = %v, 状态 = %s, 期望 %v, %s", freshness.FetchedAt, freshness.Status, fetchedAt, SettingStale)
+	}
+}
@@ -0,0 +1,90 @@
+package version
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SerialPort 串口配置(interfaces中的一项)，ERP平台及设备使用0/1表示是否启用
+// 设备端串口参数的配置格式尚未确认，工具只读写启用状态，不写入波特率等参数
+type SerialPort struct {
+	Enable int
+
+	raw string // 配置中非数字的原始内容，保存时原样写回，不做修改
+}
+
+func (p *SerialPort) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	var enable int
+	if err := json.Unmarshal(data, &enable); err == nil {
+		*p = SerialPort{Enable: enable}
+		return nil
+	}
+
+	// 非数字的配置无法确认格式，只读取其中的enable用于显示
+	var object struct {
+		Enable int `json:"enable"`
+	}
+	if len(data) == 0 || data[0] != '{' || json.Unmarshal(data, &object) != nil {
+		return fmt.Errorf("串口配置应为0或1: %s", data)
+	}
+	*p = SerialPort{Enable: object.Enable, raw: string(data)}
+	return nil
+}
+
+func (p SerialPort) MarshalJSON() ([]byte, error) {
+	if p.raw != "" {
+		return []byte(p.raw), nil
+	}
+	return json.Marshal(p.Enable)
+}
+
+// Raw 配置中非数字的原始内容，为空表示按0/1保存
+func (p SerialPort) Raw() string {
+	return p.raw
+}
+
+// SetEnable 修改启用状态，原始内容无法修改，修改后按0/1保存
+func (p *SerialPort) SetEnable(enable int) {
+	if p.raw != "" && enable == p.Enable {
+		return
+	}
+	p.Enable = enable
+	p.raw = ""
+}
+
+func (p SerialPort) String() string {
+	if p.raw != "" {
+		return fmt.Sprintf("启用: %d, 原始配置: %s", p.Enable, p.raw)
+	}
+	return fmt.Sprintf("启用: %d", p.Enable)
+}
+
+// SerialPortNames 配置中的串口设备，按名称排序
+func (s *V3Setting) SerialPortNames() []string {
+	ports := make([]string, 0, len(s.Interfaces))
+	for port := range s.Interfaces {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	return ports
+}
+
+// CheckSerialDevices 检查设备上是否存在配置中的串口设备，返回不存在的串口
+func CheckSerialDevices(flashTool IFlashTool, ports []string) ([]string, error) {
+	if len(ports) == 0 {
+		return nil, nil
+	}
+	var checks []string
+	for _, port := range ports {
+		checks = append(checks, fmt.Sprintf("test -c '%s' || echo '%s'", port, port))
+	}
+	output, err := flashTool.RunQuietCommand(strings.Join(checks, "; "))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(output), nil
+}
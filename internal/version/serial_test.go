@@ -0,0 +1,55 @@
+package version
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSerialPortJSON(t *testing.T) {
+	tests := []struct {
+		in     string
+		enable int
+		out    string
+	}{
+		{`1`, 1, `1`},
+		{`0`, 0, `0`},
+		{`{"enable":1,"baud":9600}`, 1, `{"enable":1,"baud":9600}`},
+	}
+	for _, tt := range tests {
+		var p SerialPort
+		if err := json.Unmarshal([]byte(tt.in), &p); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", tt.in, err)
+		}
+		if p.Enable != tt.enable {
+			t.Errorf("Unmarshal(%s).Enable = %d, want %d", tt.in, p.Enable, tt.enable)
+		}
+		out, err := json.Marshal(p)
+		if err != nil {
+			t.Fatalf("Marshal(%s): %v", tt.in, err)
+		}
+		if string(out) != tt.out {
+			t.Errorf("Marshal(%s) = %s, want %s", tt.in, out, tt.out)
+		}
+	}
+
+	var p SerialPort
+	if err := json.Unmarshal([]byte(`"yes"`), &p); err == nil {
+		t.Error("Unmarshal(\"yes\") should fail")
+	}
+}
+
+func TestSerialPortSetEnable(t *testing.T) {
+	var p SerialPort
+	if err := json.Unmarshal([]byte(`{"enable":1,"baud":9600}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	p.SetEnable(1)
+	if p.Raw() == "" {
+		t.Error("SetEnable with unchanged value should keep raw config")
+	}
+	p.SetEnable(0)
+	out, _ := json.Marshal(p)
+	if string(out) != `0` {
+		t.Errorf("Marshal after SetEnable(0) = %s, want 0", out)
+	}
+}
@@ -0,0 +1,111 @@
+package version
+
+import (
+	"EMInit/pkg/utils"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GatewayServices 安装脚本创建并启用的网关服务
+var GatewayServices = []string{"cgKeepalive", "frpc", "cgCollector", "cgUpdater"}
+
+// ServiceActions 支持的服务操作
+var ServiceActions = []string{"start", "stop", "restart", "enable", "disable"}
+
+// ServiceInfo systemd服务的详细状态
+type ServiceInfo struct {
+	Name     string
+	Load     string // loaded、not-found等
+	Active   string // active、inactive、failed等
+	Sub      string // running、dead、exited等
+	Enabled  string // enabled、disabled等
+	Restarts int    // 自动重启次数，systemd不支持时为-1
+	Since    string // 最近一次状态变化时间
+}
+
+func (s *ServiceInfo) String() string {
+	if s.Load == "not-found" {
+		return fmt.Sprintf("%s: 未安装", s.Name)
+	}
+	restarts := "未知"
+	if s.Restarts >= 0 {
+		restarts = strconv.Itoa(s.Restarts)
+	}
+	return fmt.Sprintf("%s: %s(%s), 开机启动: %s, 重启次数: %s, 自 %s", s.Name, s.Active, s.Sub, s.Enabled, restarts, s.Since)
+}
+
+// CollectServices 读取指定服务的运行状态、开机启动及重启次数
+func CollectServices(flashTool IFlashTool, names []string) ([]ServiceInfo, error) {
+	units := make([]string, 0, len(names))
+	for _, name := range names {
+		units = append(units, name+".service")
+	}
+	output, err := flashTool.RunQuietCommand("systemctl show -p Id,LoadState,ActiveState,SubState,UnitFileState,NRestarts,StateChangeTimestamp " + strings.Join(units, " "))
+	if err != nil {
+		return nil, err
+	}
+
+	// 每个服务一段，以空行分隔，顺序与参数一致
+	byName := make(map[string]*ServiceInfo)
+	var current *ServiceInfo
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			current = nil
+			continue
+		}
+		if current == nil {
+			current = &ServiceInfo{Restarts: -1}
+		}
+		switch key {
+		case "Id":
+			current.Name = strings.TrimSuffix(value, ".service")
+			byName[current.Name] = current
+		case "LoadState":
+			current.Load = value
+		case "ActiveState":
+			current.Active = value
+		case "SubState":
+			current.Sub = value
+		case "UnitFileState":
+			current.Enabled = value
+		case "NRestarts":
+			if n, err := strconv.Atoi(value); err == nil {
+				current.Restarts = n
+			}
+		case "StateChangeTimestamp":
+			current.Since = value
+		}
+	}
+
+	services := make([]ServiceInfo, 0, len(names))
+	for _, name := range names {
+		if info, ok := byName[name]; ok {
+			services = append(services, *info)
+		} else {
+			services = append(services, ServiceInfo{Name: name, Load: "not-found", Restarts: -1})
+		}
+	}
+	return services, nil
+}
+
+// ControlService 对网关服务执行 start、stop、restart、enable 或 disable
+func ControlService(flashTool IFlashTool, name, action string) error {
+	if !utils.ContainsString(GatewayServices, name) {
+		return fmt.Errorf("不支持的服务: %s", name)
+	}
+	if !utils.ContainsString(ServiceActions, action) {
+		return fmt.Errorf("不支持的服务操作: %s", action)
+	}
+	_, err := flashTool.RunAndWaitCommand(fmt.Sprintf("systemctl %s %s", action, name))
+	return err
+}
+
+// ServiceJournal 读取服务最近的日志
+func ServiceJournal(flashTool IFlashTool, name string, lines int) (string, error) {
+	if !utils.ContainsString(GatewayServices, name) {
+		return "", fmt.Errorf("不支持的服务: %s", name)
+	}
+	return flashTool.RunQuietCommand(fmt.Sprintf("journalctl -u %s -n %d --no-pager", name, lines))
+}
@@ -0,0 +1,345 @@
+package version
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gogf/gf/v2/util/gconv"
+	"net"
+	"net/url"
+	"regexp"
+)
+
+// KnownApps 已知的应用名称
+var KnownApps = []string{
+	"cgManager/main",
+	"cgService/serial",
+	"cgService/tcp-server",
+	"cgService/tcp-client",
+	"cgProtocol/modbus-rtu",
+	"cgProtocol/modbus-tcp",
+}
+
+var (
+	hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+	ttyPattern      = regexp.MustCompile(`^/dev/tty[A-Za-z0-9]+$`)
+	boxMd5Pattern   = regexp.MustCompile(`^([0-9a-fA-F]{32}|[0-9a-fA-F]{40}|[0-9a-fA-F]{64})$`) // MD5、SHA1或SHA256
+)
+
+// V2Setting v2版本的设备配置(boxinit接口返回的原始内容)
+type V2Setting struct {
+	Success int    `json:"success"`
+	Msg     string `json:"msg"`
+	Md5     string `json:"md5"`
+	Data    struct {
+		Brand      string `json:"brand"`
+		BoxVersion int    `json:"boxVersion"`
+		BoxUrl     string `json:"boxUrl"`
+		BoxMd5     string `json:"boxMd5"`
+		ProUrl     string `json:"proUrl"`
+		MqttIp     string `json:"mqttIp"`
+		MqttPort   string `json:"mqttPort"`
+		MqttUser   string `json:"mqttUser"`
+		MqttPwd    string `json:"mqttPwd"`
+		NetType    string `json:"netType"`
+	} `json:"data"`
+}
+
+// V3Setting v3版本的设备配置(setting/v3/<sn>.json)
+type V3Setting struct {
+	Vpn        V3Vpn                 `json:"vpn"`
+	Erp        V3Erp                 `json:"erp"`
+	Iot        V3Iot                 `json:"iot"`
+	Pro        V3Pro                 `json:"pro"`
+	App        []string              `json:"app"`
+	Interfaces map[string]SerialPort `json:"interfaces"`
+
+	raw map[string]interface{} // 原始配置，用于保存时保留未知字段
+}
+
+type V3Vpn struct {
+	ServerKey  string `json:"server_key"`
+	ServerIp   string `json:"server_ip"`
+	ServerPort int    `json:"server_port"`
+	ServerIps  string `json:"server_ips"`
+	ClientIp   string `json:"client_ip"`
+}
+
+type V3Erp struct {
+	MqttIp   string `json:"mqtt_ip"`
+	MqttPort int    `json:"mqtt_port"`
+	MqttUser string `json:"mqtt_user"`
+	MqttPwd  string `json:"mqtt_pwd"`
+	Tls      int    `json:"tls"`
+	Ca       string `json:"ca"`
+	Encrypt  int    `json:"encrypt"`
+}
+
+type V3Iot struct {
+	DataVar   int `json:"data_var"`
+	DataEvent int `json:"data_event"`
+	DataStat  int `json:"data_stat"`
+}
+
+type V3Pro struct {
+	Url      string `json:"url"`
+	MqttIp   string `json:"mqtt_ip"`
+	MqttPort int    `json:"mqtt_port"`
+	MqttUser string `json:"mqtt_user"`
+	MqttPwd  string `json:"mqtt_pwd"`
+	Tls      int    `json:"tls"`
+	Ca       string `json:"ca"`
+}
+
+// ParseV2Setting 解析v2配置
+func ParseV2Setting(data []byte) (*V2Setting, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析v2配置失败: %v", err)
+	}
+
+	// 兼容端口等字段为数字或字符串的情况
+	var setting V2Setting
+	if err := gconv.Struct(raw, &setting); err != nil {
+		return nil, fmt.Errorf("解析v2配置失败: %v", err)
+	}
+
+	return &setting, nil
+}
+
+// Validate 校验v2配置中的固件信息及项目平台信息
+func (s *V2Setting) Validate() []error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if s.Data.BoxVersion <= 0 {
+		add("data.boxVersion `%d` 无效", s.Data.BoxVersion)
+	}
+	if u, err := url.Parse(s.Data.BoxUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("data.boxUrl `%s` 不是有效的http(s)地址", s.Data.BoxUrl)
+	}
+	if !boxMd5Pattern.MatchString(s.Data.BoxMd5) {
+		add("data.boxMd5 `%s` 不是有效的校验值", s.Data.BoxMd5)
+	}
+	if s.Data.ProUrl != "" {
+		if u, err := url.Parse(s.Data.ProUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("data.proUrl `%s` 不是有效的http(s)地址", s.Data.ProUrl)
+		}
+	}
+	if s.Data.MqttIp != "" && !validHost(s.Data.MqttIp) {
+		add("data.mqttIp `%s` 不是有效的IP地址或域名", s.Data.MqttIp)
+	}
+
+	return errs
+}
+
+// ParseV3Setting 解析v3配置，字段类型错误时返回错误
+func ParseV3Setting(data []byte) (*V3Setting, error) {
+	var setting V3Setting
+	if err := json.Unmarshal(data, &setting); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("配置字段`%s`类型错误: 应为%s，实际为%s", typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+		return nil, fmt.Errorf("解析v3配置失败: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &setting.raw); err != nil {
+		return nil, fmt.Errorf("解析v3配置失败: %v", err)
+	}
+
+	return &setting, nil
+}
+
+// Marshal 序列化配置，保留原始配置中未知的字段
+func (s *V3Setting) Marshal() ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil || s.raw == nil {
+		return data, err
+	}
+
+	var current map[string]interface{}
+	if err := json.Unmarshal(data, &current); err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]interface{}, len(s.raw))
+	for key, value := range s.raw {
+		merged[key] = value
+	}
+	for key, value := range current {
+		// 固定结构的配置段保留未知字段，其余字段直接覆盖
+		base, ok1 := merged[key].(map[string]interface{})
+		section, ok2 := value.(map[string]interface{})
+		if ok1 && ok2 && key != "interfaces" {
+			// 复制后合并，不修改解析时保存的原始内容
+			combined := make(map[string]interface{}, len(base)+len(section))
+			for k, v := range base {
+				combined[k] = v
+			}
+			for k, v := range section {
+				combined[k] = v
+			}
+			merged[key] = combined
+			continue
+		}
+		merged[key] = value
+	}
+
+	return json.Marshal(merged)
+}
+
+// Validate 校验配置内容，返回发现的所有问题
+func (s *V3Setting) Validate() []error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	// MQTT配置
+	checkMqtt := func(section, ip string, port int, user, pwd string, tls int, ca string) {
+		if ip == "" {
+			add("%s.mqtt_ip 不能为空", section)
+		} else if !validHost(ip) {
+			add("%s.mqtt_ip `%s` 不是有效的IP地址或域名", section, ip)
+		}
+		if port < 1 || port > 65535 {
+			add("%s.mqtt_port `%d` 超出端口范围(1-65535)", section, port)
+		}
+		if user == "" {
+			add("%s.mqtt_user 不能为空", section)
+		}
+		if pwd == "" {
+			add("%s.mqtt_pwd 不能为空", section)
+		}
+		if tls != 0 && tls != 1 {
+			add("%s.tls 只能为0或1", section)
+		}
+		if tls == 1 && ca == "" {
+			add("%s 启用TLS时 ca 不能为空", section)
+		}
+	}
+	checkMqtt("erp", s.Erp.MqttIp, s.Erp.MqttPort, s.Erp.MqttUser, s.Erp.MqttPwd, s.Erp.Tls, s.Erp.Ca)
+	checkMqtt("pro", s.Pro.MqttIp, s.Pro.MqttPort, s.Pro.MqttUser, s.Pro.MqttPwd, s.Pro.Tls, s.Pro.Ca)
+	if s.Erp.Encrypt != 0 && s.Erp.Encrypt != 1 {
+		add("erp.encrypt 只能为0或1")
+	}
+
+	// 项目平台地址
+	if u, err := url.Parse(s.Pro.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("pro.url `%s` 不是有效的http(s)地址", s.Pro.Url)
+	}
+
+	// VPN配置，未配置服务器时不使用VPN
+	if s.Vpn.ServerIp != "" {
+		if !validHost(s.Vpn.ServerIp) {
+			add("vpn.server_ip `%s` 不是有效的IP地址或域名", s.Vpn.ServerIp)
+		}
+		if s.Vpn.ServerPort < 1 || s.Vpn.ServerPort > 65535 {
+			add("vpn.server_port `%d` 超出端口范围(1-65535)", s.Vpn.ServerPort)
+		}
+		if s.Vpn.ServerKey == "" {
+			add("vpn.server_key 不能为空")
+		}
+	}
+	if s.Vpn.ClientIp != "" && net.ParseIP(s.Vpn.ClientIp) == nil {
+		add("vpn.client_ip `%s` 不是有效的IP地址", s.Vpn.ClientIp)
+	}
+
+	// 数据上报开关
+	iot := []struct {
+		name  string
+		value int
+	}{{"data_var", s.Iot.DataVar}, {"data_event", s.Iot.DataEvent}, {"data_stat", s.Iot.DataStat}}
+	for _, item := range iot {
+		if item.value != 0 && item.value != 1 {
+			add("iot.%s 只能为0或1", item.name)
+		}
+	}
+
+	// 应用列表
+	if len(s.App) == 0 {
+		add("app 不能为空")
+	}
+	seen := make(map[string]bool)
+	hasManager := false
+	for _, app := range s.App {
+		if seen[app] {
+			add("app `%s` 重复", app)
+		}
+		seen[app] = true
+		if app == "cgManager/main" {
+			hasManager = true
+		}
+		if !isKnownApp(app) {
+			add("app `%s` 不是已知的应用", app)
+		}
+	}
+	if len(s.App) > 0 && !hasManager {
+		add("app 必须包含 cgManager/main")
+	}
+
+	// 串口配置
+	enabled := false
+	for _, port := range s.SerialPortNames() {
+		if !ttyPattern.MatchString(port) {
+			add("interfaces `%s` 不是有效的串口设备", port)
+		}
+		if enable := s.Interfaces[port].Enable; enable != 0 && enable != 1 {
+			add("interfaces `%s` 只能为0或1", port)
+		}
+		if s.Interfaces[port].Enable == 1 {
+			enabled = true
+		}
+	}
+	if enabled && !seen["cgService/serial"] {
+		add("启用串口时 app 需包含 cgService/serial")
+	}
+
+	return errs
+}
+
+func isKnownApp(app string) bool {
+	for _, known := range KnownApps {
+		if app == known {
+			return true
+		}
+	}
+	return false
+}
+
+// validHost 是否为有效的IP地址或域名
+func validHost(host string) bool {
+	return net.ParseIP(host) != nil || (len(host) <= 253 && hostnamePattern.MatchString(host))
+}
+
+// ConvertV2Setting 将v2配置尽可能转换为v3配置，无法转换的内容通过提示返回
+func ConvertV2Setting(v2 *V2Setting) (*V3Setting, []string) {
+	var warnings []string
+	setting := &V3Setting{
+		App:        []string{"cgManager/main"},
+		Interfaces: map[string]SerialPort{},
+	}
+
+	// 项目平台信息
+	setting.Pro.Url = v2.Data.ProUrl
+	setting.Pro.MqttIp = v2.Data.MqttIp
+	setting.Pro.MqttUser = v2.Data.MqttUser
+	setting.Pro.MqttPwd = v2.Data.MqttPwd
+	if v2.Data.MqttPort != "" {
+		if _, err := fmt.Sscanf(v2.Data.MqttPort, "%d", &setting.Pro.MqttPort); err != nil {
+			warnings = append(warnings, fmt.Sprintf("项目平台MQTT端口`%s`无效", v2.Data.MqttPort))
+		}
+	}
+	if setting.Pro.MqttIp == "" {
+		warnings = append(warnings, "v2配置中没有项目平台MQTT地址")
+	}
+
+	// v2配置中没有ERP平台、VPN和应用信息
+	warnings = append(warnings, "ERP平台MQTT信息无法从v2配置转换，设备联网后将从ERP自动获取")
+	warnings = append(warnings, "应用列表仅保留`cgManager/main`，请在ERP平台上配置设备所使用的程序")
+
+	return setting, warnings
+}
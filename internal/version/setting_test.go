@@ -0,0 +1,105 @@
+package version
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ERP返回的v2配置样例应能通过校验
+func TestV2SettingSamples(t *testing.T) {
+	files, err := filepath.Glob("../../setting/v2/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("未找到v2配置样例")
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setting, err := ParseV2Setting(data)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		for _, err := range setting.Validate() {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+// ERP返回的v3配置样例应能通过校验，下载时校验不通过的配置不会保存
+func TestV3SettingSamples(t *testing.T) {
+	files, err := filepath.Glob("../../setting/v3/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("未找到v3配置样例")
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setting, err := ParseV3Setting(data)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		for _, err := range setting.Validate() {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestBoxMd5Pattern(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"774442c5b02a409d7bb3b1a692f7ec0d", true},
+		{"da39a3ee5e6b4b0d3255bfef95601890afd80709", true},
+		{"5e1e09c99581dfe5491909671500eb331a8f9fc00cff7fdb8849a2572f4062f6", true},
+		{"", false},
+		{"774442c5b02a409d7bb3b1a692f7ec0", false},
+		{"zz4442c5b02a409d7bb3b1a692f7ec0d", false},
+	}
+	for _, tt := range tests {
+		if got := boxMd5Pattern.MatchString(tt.value); got != tt.valid {
+			t.Errorf("boxMd5Pattern(%q) = %v, 期望 %v", tt.value, got, tt.valid)
+		}
+	}
+}
+
+// Marshal不应修改解析时保存的原始内容
+func TestV3SettingMarshalKeepsRaw(t *testing.T) {
+	setting, err := ParseV3Setting([]byte(`{"erp":{"mqtt_ip":"1.1.1.1","extra":"keep"},"unknown":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setting.Erp.MqttIp = "2.2.2.2"
+	data, err := setting.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var merged struct {
+		Erp map[string]interface{} `json:"erp"`
+	}
+	if err := json.Unmarshal(data, &merged); err != nil {
+		t.Fatal(err)
+	}
+	if merged.Erp["mqtt_ip"] != "2.2.2.2" || merged.Erp["extra"] != "keep" {
+		t.Errorf("合并结果错误: %v", merged.Erp)
+	}
+	if raw := setting.raw["erp"].(map[string]interface{}); raw["mqtt_ip"] != "1.1.1.1" {
+		t.Errorf("原始内容被修改: %v", raw)
+	}
+}
@@ -0,0 +1,100 @@
+package version
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// deviceSNFiles 设备上可能存在的SN标签文件，每个文件只包含SN
+var deviceSNFiles = []string{"/etc/device_sn", "/etc/sn", "/datas/sn"}
+
+// CollectDeviceSN 读取设备上各来源记录的SN，如 cgManager.yaml、frpc.toml 及SN标签文件，不存在的来源不记录
+func CollectDeviceSN(flashTool IFlashTool) map[string]string {
+	sources := make(map[string]string)
+	if output, err := flashTool.RunQuietCommand(fmt.Sprintf("cat %s/data/config/cgManager.yaml", v3RootDir)); err == nil {
+		if sn := parseManagerSN([]byte(output)); sn != "" {
+			sources["cgManager.yaml"] = sn
+		}
+	}
+	if output, err := flashTool.RunQuietCommand("cat " + remoteFrpcConfig); err == nil {
+		if sn := parseFrpcSN([]byte(output)); sn != "" {
+			sources["frpc.toml"] = sn
+		}
+	}
+	for _, file := range deviceSNFiles {
+		if output, err := flashTool.RunQuietCommand("cat " + file); err == nil {
+			if sn := strings.Trim(strings.TrimSpace(output), "\x00"); sn != "" {
+				sources[file] = sn
+			}
+		}
+	}
+	return sources
+}
+
+// SNCheck 输入的SN与设备上记录的SN的核对结果
+type SNCheck struct {
+	Input      string
+	Device     map[string]string // 设备上各来源的SN
+	HasSetting bool              // 主机上是否有输入SN的初始配置(v2或v3)
+}
+
+// CheckSN 核对输入的SN，flashTool为nil(未连接设备)时不读取设备上的SN
+func CheckSN(flashTool IFlashTool, input string) *SNCheck {
+	check := &SNCheck{Input: strings.TrimSpace(input), Device: make(map[string]string)}
+	if flashTool != nil {
+		check.Device = CollectDeviceSN(flashTool)
+	}
+	if check.Input != "" {
+		// 迁移时主机上可能只有v2配置，任一版本的配置存在即可
+		check.HasSetting = SettingExists("v2", check.Input) || SettingExists("v3", check.Input)
+	}
+	return check
+}
+
+// DeviceSN 设备上记录的SN，优先使用cgManager.yaml，没有时返回空
+func (c *SNCheck) DeviceSN() string {
+	_, sn := c.primary()
+	return sn
+}
+
+// primary 设备上优先使用的SN来源，依次为cgManager.yaml、frpc.toml、SN标签文件
+func (c *SNCheck) primary() (string, string) {
+	for _, source := range append([]string{"cgManager.yaml", "frpc.toml"}, c.sources()...) {
+		if sn := c.Device[source]; sn != "" {
+			return source, sn
+		}
+	}
+	return "", ""
+}
+
+// Mismatches 不一致的SN及主机上缺少配置的提示，输入为空时只核对设备上各来源之间是否一致
+func (c *SNCheck) Mismatches() []string {
+	var mismatches []string
+	expected, against := c.Input, "输入的SN"
+	if expected == "" {
+		var source string
+		source, expected = c.primary()
+		against = source + " 中的SN"
+	}
+
+	for _, source := range c.sources() {
+		if sn := c.Device[source]; sn != expected {
+			mismatches = append(mismatches, fmt.Sprintf("设备上 %s 中的SN `%s` 与%s `%s` 不一致", source, sn, against, expected))
+		}
+	}
+	// 输错的SN通常没有下载过配置
+	if c.Input != "" && !c.HasSetting {
+		mismatches = append(mismatches, fmt.Sprintf("主机上没有SN `%s` 的初始配置，请核对SN是否输入有误", c.Input))
+	}
+	return mismatches
+}
+
+func (c *SNCheck) sources() []string {
+	sources := make([]string, 0, len(c.Device))
+	for source := range c.Device {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
@@ -0,0 +1,189 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 设备状态的告警阈值
+const (
+	diskUsageWarn   = 90 // /datas 使用率(%)
+	memoryUsageWarn = 90 // 内存使用率(%)
+	cpuTempWarn     = 80 // CPU温度(℃)
+)
+
+// ServiceState 设备上的服务状态
+type ServiceState struct {
+	Name   string
+	Active string // active、inactive、failed等
+	Sub    string // running、dead、exited等
+}
+
+// DeviceStatus 设备运行状态
+type DeviceStatus struct {
+	CollectedAt time.Time
+	Uptime      time.Duration
+	Load        string  // 1、5、15分钟平均负载
+	MemTotal    int64   // KB
+	MemUsed     int64   // KB
+	DiskTotal   int64   // /datas 总大小，KB
+	DiskUsed    int64   // /datas 已用，KB
+	CPUTemp     float64 // ℃，读取不到时为0
+	Kernel      string
+	OS          string
+	Addresses   []NetworkAddress
+	Services    []ServiceState
+}
+
+// statusScript 一次读取所有状态，各部分以 @<名称> 开头
+var statusScript = strings.Join([]string{
+	"echo @uptime; cat /proc/uptime",
+	"echo @load; cat /proc/loadavg",
+	"echo @mem; grep -E '^(MemTotal|MemAvailable):' /proc/meminfo",
+	"echo @disk; df -Pk /datas | tail -1",
+	"echo @temp; cat /sys/class/thermal/thermal_zone0/temp",
+	"echo @kernel; uname -r",
+	"echo @os; . /etc/os-release && echo \"$PRETTY_NAME\"",
+	"echo @addr; ip -o -4 addr show",
+	"echo @services; systemctl list-units --all --plain --no-legend --type=service 'cg*' 'frpc*'",
+}, "; ")
+
+// CollectStatus 读取设备运行状态，单项读取失败时记录为空
+func CollectStatus(flashTool IFlashTool) (*DeviceStatus, error) {
+	output, err := flashTool.RunQuietCommand(statusScript + "; true")
+	if err != nil {
+		return nil, err
+	}
+
+	sections := make(map[string][]string)
+	section := ""
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "@") {
+			section = strings.TrimPrefix(strings.TrimSpace(line), "@")
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			sections[section] = append(sections[section], line)
+		}
+	}
+	first := func(name string) string {
+		if lines := sections[name]; len(lines) > 0 {
+			return strings.TrimSpace(lines[0])
+		}
+		return ""
+	}
+
+	status := &DeviceStatus{
+		CollectedAt: time.Now(),
+		Kernel:      first("kernel"),
+		OS:          first("os"),
+		Addresses:   parseIPAddresses(strings.Join(sections["addr"], "\n")),
+	}
+
+	if fields := strings.Fields(first("uptime")); len(fields) > 0 {
+		if seconds, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			status.Uptime = time.Duration(seconds) * time.Second
+		}
+	}
+	if fields := strings.Fields(first("load")); len(fields) >= 3 {
+		status.Load = strings.Join(fields[:3], " ")
+	}
+
+	var memAvailable int64
+	for _, line := range sections["mem"] {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		value, _ := strconv.ParseInt(fields[1], 10, 64)
+		switch fields[0] {
+		case "MemTotal:":
+			status.MemTotal = value
+		case "MemAvailable:":
+			memAvailable = value
+		}
+	}
+	if status.MemTotal > 0 {
+		status.MemUsed = status.MemTotal - memAvailable
+	}
+
+	// Filesystem 1024-blocks Used Available Capacity Mounted
+	if fields := strings.Fields(first("disk")); len(fields) >= 6 {
+		status.DiskTotal, _ = strconv.ParseInt(fields[1], 10, 64)
+		status.DiskUsed, _ = strconv.ParseInt(fields[2], 10, 64)
+	}
+
+	if temp, err := strconv.ParseFloat(first("temp"), 64); err == nil {
+		// 单位为千分之一摄氏度
+		status.CPUTemp = temp / 1000
+	}
+
+	// unit load active sub description
+	for _, line := range sections["services"] {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		status.Services = append(status.Services, ServiceState{
+			Name:   strings.TrimSuffix(fields[0], ".service"),
+			Active: fields[2],
+			Sub:    fields[3],
+		})
+	}
+
+	return status, nil
+}
+
+// Problems 设备状态中的异常项，为空表示运行正常
+func (s *DeviceStatus) Problems() []string {
+	var problems []string
+	if s.DiskTotal > 0 && s.DiskUsed*100/s.DiskTotal >= diskUsageWarn {
+		problems = append(problems, fmt.Sprintf("/datas 使用率超过 %d%%", diskUsageWarn))
+	}
+	if s.MemTotal > 0 && s.MemUsed*100/s.MemTotal >= memoryUsageWarn {
+		problems = append(problems, fmt.Sprintf("内存使用率超过 %d%%", memoryUsageWarn))
+	}
+	if s.CPUTemp >= cpuTempWarn {
+		problems = append(problems, fmt.Sprintf("CPU温度超过 %d℃", cpuTempWarn))
+	}
+	if len(s.Services) == 0 {
+		problems = append(problems, "未找到 cg* 及 frpc 服务")
+	}
+	for _, service := range s.Services {
+		if service.Active != "active" {
+			problems = append(problems, fmt.Sprintf("服务 %s 未运行(%s)", service.Name, service.Active))
+		}
+	}
+	return problems
+}
+
+// Memory 内存使用情况
+func (s *DeviceStatus) Memory() string {
+	if s.MemTotal == 0 {
+		return "未知"
+	}
+	return fmt.Sprintf("%d / %d MB (%d%%)", s.MemUsed/1024, s.MemTotal/1024, s.MemUsed*100/s.MemTotal)
+}
+
+// Disk /datas 使用情况
+func (s *DeviceStatus) Disk() string {
+	if s.DiskTotal == 0 {
+		return "未知"
+	}
+	return fmt.Sprintf("%d / %d MB (%d%%)", s.DiskUsed/1024, s.DiskTotal/1024, s.DiskUsed*100/s.DiskTotal)
+}
+
+// Temperature CPU温度
+func (s *DeviceStatus) Temperature() string {
+	if s.CPUTemp == 0 {
+		return "未知"
+	}
+	return fmt.Sprintf("%.1f℃", s.CPUTemp)
+}
+
+// UptimeText 运行时间
+func (s *DeviceStatus) UptimeText() string {
+	return formatAge(s.Uptime)
+}
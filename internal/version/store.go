@@ -0,0 +1,412 @@
+package version
+
+import (
+	"EMInit/pkg/utils"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const settingDir = "setting" // 主机上设备配置的存放目录
+
+// snPattern 设备SN只允许字母、数字、下划线及短横线，SN会写入配置模板及主机上的文件路径
+var snPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidateSN 校验设备SN格式
+func ValidateSN(sn string) error {
+	if !snPattern.MatchString(sn) {
+		return fmt.Errorf("设备SN `%s` 无效，只能包含字母、数字、下划线及短横线", sn)
+	}
+	return nil
+}
+
+// SettingPath 主机上设备配置文件路径，如 setting/v3/<sn>.json
+func SettingPath(ver, sn string) string {
+	return filepath.Join(settingDir, ver, fmt.Sprintf("%s.json", sn))
+}
+
+// SettingExists 主机上是否存在设备配置
+func SettingExists(ver, sn string) bool {
+	_, err := os.Stat(SettingPath(ver, sn))
+	return err == nil
+}
+
+// ReadSetting 读取主机上的设备配置，加密的配置只在内存中解密
+func ReadSetting(ver, sn string) ([]byte, error) {
+	data, err := utils.ReadFile(SettingPath(ver, sn))
+	if err != nil {
+		return nil, err
+	}
+	return decryptSetting(data)
+}
+
+// WriteSetting 保存设备配置到主机，已设置主密码时加密保存
+func WriteSetting(ver, sn string, data []byte) error {
+	if SettingEncryptionEnabled() {
+		encrypted, err := encryptSetting(data)
+		if err != nil {
+			return err
+		}
+		data = encrypted
+	}
+
+	if err := os.MkdirAll(filepath.Join(settingDir, ver), 0755); err != nil {
+		return err
+	}
+	return utils.WriteFile(SettingPath(ver, sn), data)
+}
+
+// WriteEditedSetting 保存在本地修改的设备配置，并在来源信息中记录修改
+// 从ERP平台下载的配置改为本地修改来源，之后下载时先备份；获取时间保持不变，有效期仍按原配置计算
+func WriteEditedSetting(ver, sn string, data []byte) error {
+	meta, err := ReadSettingMeta(ver, sn)
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		meta = &SettingMeta{Source: SettingSourceEdited, CreatedAt: hostSettingFetchedAt(ver, sn)}
+	}
+	if meta.Source == SettingSourceErp {
+		meta.Source = SettingSourceEdited
+	}
+	meta.EditedAt = time.Now().Format("2006-01-02 15:04:05")
+	meta.Sha256 = "" // 内容已与下载时不同
+
+	if err := WriteSetting(ver, sn, data); err != nil {
+		return err
+	}
+	return WriteSettingMeta(ver, sn, meta)
+}
+
+// uploadSetting 读取并解密设备配置，直接从内存上传到设备
+func uploadSetting(flashTool IFlashTool, ver, sn, remotePath string) error {
+	data, err := ReadSetting(ver, sn)
+	if err != nil {
+		return err
+	}
+	return flashTool.UploadContent(data, remotePath)
+}
+
+const (
+	settingKeyFile = "master.json" // 主密码校验信息，位于配置目录下
+	settingKdfIter = 100000        // PBKDF2迭代次数
+)
+
+// ErrSettingLocked 配置已加密但尚未输入主密码
+var ErrSettingLocked = errors.New("设备配置已加密，请先输入主密码解锁")
+
+// settingKeyInfo 主密码的盐值和校验值，不包含密码本身
+type settingKeyInfo struct {
+	Salt  string `json:"salt"`
+	Iter  int    `json:"iter"`
+	Check string `json:"check"`
+}
+
+// settingEnvelope 加密后的配置文件内容
+type settingEnvelope struct {
+	Encrypted int    `json:"encrypted"`
+	Iv        string `json:"iv"`
+	Data      string `json:"data"`
+	Mac       string `json:"mac"`
+}
+
+// settingKey 主密码派生的密钥，只保存在内存中
+var settingKey struct {
+	sync.RWMutex
+	enc []byte // AES-256密钥
+	mac []byte // HMAC密钥
+}
+
+// SettingEncryptionEnabled 是否已设置主密码
+func SettingEncryptionEnabled() bool {
+	_, err := os.Stat(filepath.Join(settingDir, settingKeyFile))
+	return err == nil
+}
+
+// SettingUnlocked 是否已输入主密码
+func SettingUnlocked() bool {
+	settingKey.RLock()
+	defer settingKey.RUnlock()
+	return settingKey.enc != nil
+}
+
+// UnlockSettings 输入主密码解锁配置，首次使用时设置主密码
+func UnlockSettings(password string) error {
+	if password == "" {
+		return errors.New("主密码不能为空")
+	}
+
+	keyPath := filepath.Join(settingDir, settingKeyFile)
+	var info settingKeyInfo
+	data, err := os.ReadFile(keyPath)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &info); err != nil {
+			return fmt.Errorf("解析主密码信息失败: %v", err)
+		}
+	case os.IsNotExist(err):
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		info = settingKeyInfo{Salt: base64.StdEncoding.EncodeToString(salt), Iter: settingKdfIter}
+	default:
+		return err
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(info.Salt)
+	if err != nil {
+		return fmt.Errorf("解析主密码信息失败: %v", err)
+	}
+	key := utils.DeriveKey(password, salt, info.Iter, 64)
+	encKey, macKey := key[:32], key[32:]
+	check := hex.EncodeToString(settingMac(macKey, []byte(settingKeyFile)))
+
+	if info.Check == "" {
+		info.Check = check
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(settingDir, 0755); err != nil {
+			return err
+		}
+		if err := utils.WriteFile(keyPath, data); err != nil {
+			return err
+		}
+	} else if !hmac.Equal([]byte(check), []byte(info.Check)) {
+		return errors.New("主密码错误")
+	}
+
+	settingKey.Lock()
+	settingKey.enc, settingKey.mac = encKey, macKey
+	settingKey.Unlock()
+	return nil
+}
+
+// EncryptAllSettings 加密主机上所有明文保存的设备配置，返回加密的文件数
+func EncryptAllSettings() (int, error) {
+	if !SettingUnlocked() {
+		return 0, ErrSettingLocked
+	}
+
+	files, err := filepath.Glob(filepath.Join(settingDir, "*", "*.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, file := range files {
+		if strings.HasSuffix(file, ".meta.json") {
+			continue
+		}
+
+		data, err := utils.ReadFile(file)
+		if err != nil {
+			return count, err
+		}
+		if isEncryptedSetting(data) {
+			continue
+		}
+
+		encrypted, err := encryptSetting(data)
+		if err != nil {
+			return count, err
+		}
+		if err := utils.WriteFile(file, encrypted); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// encryptSetting 使用AES-256-CBC加密配置，并附加HMAC用于校验
+func encryptSetting(data []byte) ([]byte, error) {
+	settingKey.RLock()
+	encKey, macKey := settingKey.enc, settingKey.mac
+	settingKey.RUnlock()
+	if encKey == nil {
+		return nil, ErrSettingLocked
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := utils.EncryptAES(string(data), string(encKey), string(iv))
+	if err != nil {
+		return nil, fmt.Errorf("加密配置失败: %v", err)
+	}
+
+	envelope := settingEnvelope{
+		Encrypted: 1,
+		Iv:        base64.StdEncoding.EncodeToString(iv),
+		Data:      ciphertext,
+	}
+	envelope.Mac = hex.EncodeToString(settingMac(macKey, []byte(envelope.Iv+envelope.Data)))
+	return json.Marshal(envelope)
+}
+
+// decryptSetting 解密配置，明文配置直接返回
+func decryptSetting(data []byte) ([]byte, error) {
+	var envelope settingEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.Encrypted != 1 {
+		return data, nil
+	}
+
+	settingKey.RLock()
+	encKey, macKey := settingKey.enc, settingKey.mac
+	settingKey.RUnlock()
+	if encKey == nil {
+		return nil, ErrSettingLocked
+	}
+
+	mac := hex.EncodeToString(settingMac(macKey, []byte(envelope.Iv+envelope.Data)))
+	if !hmac.Equal([]byte(mac), []byte(envelope.Mac)) {
+		return nil, errors.New("配置校验失败，文件已损坏或主密码不匹配")
+	}
+
+	iv, err := base64.StdEncoding.DecodeString(envelope.Iv)
+	if err != nil {
+		return nil, fmt.Errorf("解密配置失败: %v", err)
+	}
+	plaintext, err := utils.DecryptAES(envelope.Data, string(encKey), string(iv))
+	if err != nil {
+		return nil, fmt.Errorf("解密配置失败: %v", err)
+	}
+	return []byte(plaintext), nil
+}
+
+func isEncryptedSetting(data []byte) bool {
+	var envelope settingEnvelope
+	return json.Unmarshal(data, &envelope) == nil && envelope.Encrypted == 1
+}
+
+func settingMac(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// 设备配置来源
+const (
+	SettingSourceErp      = "erp"      // 从ERP平台下载
+	SettingSourceTemplate = "template" // 本地从模板生成
+	SettingSourceDevice   = "device"   // 从设备上保存
+	SettingSourceV2       = "v2"       // 从v2配置转换
+	SettingSourceEdited   = "edited"   // 从ERP平台下载后在本地修改
+)
+
+// SettingMeta 设备配置的来源信息，保存在配置文件旁的 <sn>.meta.json
+type SettingMeta struct {
+	Source    string `json:"source"`
+	Template  string `json:"template,omitempty"`
+	CreatedAt string `json:"created_at"` // 记录时间，从ERP下载时为获取时间，从v2配置转换时为v2配置的获取时间
+
+	ConvertedFrom string `json:"converted_from,omitempty"` // 从v2配置转换时记录v2配置的位置
+	EditedAt      string `json:"edited_at,omitempty"`      // 最近一次在本地修改的时间
+
+	// 以下仅从ERP平台下载时记录
+	Url       string `json:"url,omitempty"`        // 请求地址
+	ServerMd5 string `json:"server_md5,omitempty"` // 服务器提供的MD5
+	Md5Check  string `json:"md5_check,omitempty"`  // MD5校验结果
+	Decrypt   string `json:"decrypt,omitempty"`    // 解密状态
+	Sha256    string `json:"sha256,omitempty"`     // 保存的配置内容的SHA256
+}
+
+// MD5校验结果及解密状态
+const (
+	Md5CheckPassed      = "通过"
+	Md5CheckNotProvided = "服务器未提供"
+	DecryptNone         = "未加密"
+	DecryptOK           = "解密成功"
+)
+
+// LocallyAuthored 配置是否为本地编写，需要与ERP平台核对
+func (m *SettingMeta) LocallyAuthored() bool {
+	return m != nil && m.Source != SettingSourceErp
+}
+
+// Describe 配置来源说明
+func (m *SettingMeta) Describe() string {
+	switch m.Source {
+	case SettingSourceTemplate:
+		return fmt.Sprintf("模板 %s 生成", m.Template)
+	case SettingSourceDevice:
+		return "从设备保存"
+	case SettingSourceV2:
+		return fmt.Sprintf("从v2配置 %s 转换", m.ConvertedFrom)
+	case SettingSourceEdited:
+		return fmt.Sprintf("ERP平台下载后于 %s 本地修改", m.EditedAt)
+	default:
+		return "ERP平台下载"
+	}
+}
+
+func settingMetaPath(ver, sn string) string {
+	return filepath.Join(settingDir, ver, fmt.Sprintf("%s.meta.json", sn))
+}
+
+// ReadSettingMeta 读取设备配置的来源信息，不存在时返回nil
+func ReadSettingMeta(ver, sn string) (*SettingMeta, error) {
+	data, err := os.ReadFile(settingMetaPath(ver, sn))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var meta SettingMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("解析配置来源信息失败: %v", err)
+	}
+	return &meta, nil
+}
+
+// WriteSettingMeta 保存设备配置的来源信息
+func WriteSettingMeta(ver, sn string, meta *SettingMeta) error {
+	if meta.CreatedAt == "" {
+		meta.CreatedAt = time.Now().Format("2006-01-02 15:04:05")
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(settingDir, ver), 0755); err != nil {
+		return err
+	}
+	return utils.WriteFile(settingMetaPath(ver, sn), data)
+}
+
+// BackupSetting 备份主机上的设备配置，返回备份文件路径
+func BackupSetting(ver, sn, tag string) (string, error) {
+	// 直接复制文件内容，加密的配置备份后仍是加密的
+	data, err := utils.ReadFile(SettingPath(ver, sn))
+	if err != nil {
+		return "", err
+	}
+
+	backupPath := filepath.Join(settingDir, ver, fmt.Sprintf("%s.%s.%s.json", sn, tag, time.Now().Format("20060102150405")))
+	if err := utils.WriteFile(backupPath, data); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
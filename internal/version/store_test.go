@@ -0,0 +1,60 @@
+package version
+
+import (
+	"os"
+	"testing"
+)
+
+// chdirTemp 切换到临时目录，测试结束后恢复
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestWriteEditedSetting(t *testing.T) {
+	chdirTemp(t)
+
+	sn := "TESTSN"
+	if err := WriteSetting("v3", sn, []byte(`{"app":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	downloaded := &SettingMeta{Source: SettingSourceErp, CreatedAt: "2026-01-02 03:04:05", Sha256: "abc"}
+	if err := WriteSettingMeta("v3", sn, downloaded); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteEditedSetting("v3", sn, []byte(`{"app":["cgService/serial"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := ReadSettingMeta("v3", sn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Source != SettingSourceEdited || !meta.LocallyAuthored() {
+		t.Errorf("来源 = %s, 应为本地修改", meta.Source)
+	}
+	if meta.CreatedAt != downloaded.CreatedAt {
+		t.Errorf("获取时间 = %s, 应保持 %s", meta.CreatedAt, downloaded.CreatedAt)
+	}
+	if meta.EditedAt == "" || meta.Sha256 != "" {
+		t.Errorf("修改时间 = %q, SHA256 = %q", meta.EditedAt, meta.Sha256)
+	}
+
+	// 模板生成的配置修改后仍保留原来源
+	if err := WriteSettingMeta("v3", sn, &SettingMeta{Source: SettingSourceTemplate, Template: "default"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteEditedSetting("v3", sn, []byte(`{"app":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if meta, _ := ReadSettingMeta("v3", sn); meta.Source != SettingSourceTemplate {
+		t.Errorf("来源 = %s, 应保持 %s", meta.Source, SettingSourceTemplate)
+	}
+}
@@ -0,0 +1,421 @@
+package version
+
+import (
+	"EMInit/pkg/utils"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	remoteSyncDir  = "/tmpcf/sync" // 设备上存放待替换文件的临时目录
+	remoteFrpcDir  = "/datas/frpc" // 设备上frpc目录
+	localFrpcDir   = "share/frpc"  // 本地frpc目录
+	localBsdiffDeb = "share/bsdiff/bsdiff_4.3-21_armhf.deb"
+)
+
+// localHashCache 本地文件hash缓存，避免每次同步都重新计算大文件的hash值
+var localHashCache = utils.NewHashCache("cache/file_hash.json")
+
+// fileSyncer 按hash值将本地文件同步到设备，只传输有变化或缺失的文件
+type fileSyncer struct {
+	IFlashTool
+
+	archiveDir   string                       // 本地旧版本存档目录，为空时不使用差分补丁
+	tempDir      string                       // 本地临时目录
+	remoteHashes map[string]map[string]string // 设备目录 -> 文件名 -> sha256
+	changed      map[string]int               // 设备目录 -> 更新的文件数
+}
+
+func newFileSyncer(flashTool IFlashTool, archiveDir, tempDir string) *fileSyncer {
+	return &fileSyncer{
+		IFlashTool:   flashTool,
+		archiveDir:   archiveDir,
+		tempDir:      tempDir,
+		remoteHashes: make(map[string]map[string]string),
+		changed:      make(map[string]int),
+	}
+}
+
+// begin 准备本地及设备上的临时目录
+func (s *fileSyncer) begin() error {
+	if err := os.MkdirAll(s.tempDir, 0755); err != nil {
+		return err
+	}
+	_, err := s.RunAndWaitCommand(fmt.Sprintf("rm -rf %s && mkdir -p %s", remoteSyncDir, remoteSyncDir))
+	return err
+}
+
+// end 清理临时目录并保存hash缓存
+func (s *fileSyncer) end() {
+	os.RemoveAll(s.tempDir)
+	s.RunAndWaitCommand("rm -rf " + remoteSyncDir)
+	if err := localHashCache.Save(); err != nil {
+		s.AppendOutput("保存文件hash缓存失败: " + err.Error())
+	}
+}
+
+// total 更新的文件总数
+func (s *fileSyncer) total() int {
+	total := 0
+	for _, n := range s.changed {
+		total += n
+	}
+	return total
+}
+
+// stage 将生成的内容写入本地临时文件，用于同步
+func (s *fileSyncer) stage(name string, content []byte) (string, error) {
+	dir := filepath.Join(s.tempDir, "stage")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	file := filepath.Join(dir, name)
+	return file, utils.WriteFile(file, content)
+}
+
+// syncDir 同步本地目录下的所有文件(不包含子目录)
+func (s *fileSyncer) syncDir(localDir, remoteDir string) error {
+	files, err := os.ReadDir(localDir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if _, err := s.syncFile(filepath.Join(localDir, file.Name()), remoteDir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncFile 同步单个文件到设备目录，内容相同时跳过，返回是否有更新
+func (s *fileSyncer) syncFile(localFile, remoteDir string) (bool, error) {
+	hashes, err := s.hashes(remoteDir)
+	if err != nil {
+		return false, err
+	}
+
+	name := filepath.Base(localFile)
+	localHash, err := localHashCache.FileSHA256(localFile)
+	if err != nil {
+		return false, err
+	}
+
+	if hashes[name] == localHash {
+		s.AppendOutput(fmt.Sprintf("`%s/%s`无变化，跳过", remoteDir, name))
+		return false, nil
+	}
+
+	// 查找设备上同一组件的其他版本
+	var oldName string
+	if component, _, ok := ParseComponentFile(name); ok {
+		for remoteName := range hashes {
+			if c, _, ok := ParseComponentFile(remoteName); ok && c == component && remoteName != name {
+				oldName = remoteName
+				break
+			}
+		}
+	}
+
+	tmpFile := fmt.Sprintf("%s/%s", remoteSyncDir, name)
+	if err := s.uploadWithPatch(localFile, remoteDir, oldName, tmpFile, hashes[oldName]); err != nil {
+		return false, err
+	}
+
+	if err := s.install(tmpFile, remoteDir, name, oldName, localHash); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// install 校验上传到设备临时目录的文件，然后替换到目标目录并删除旧版本
+func (s *fileSyncer) install(tmpFile, remoteDir, name, oldName, hash string) error {
+	// 校验hash值
+	if _, err := s.RunAndWaitCommand(fmt.Sprintf("echo \"%s  %s\" | sha256sum -c -", hash, tmpFile)); err != nil {
+		return fmt.Errorf("`%s`校验失败，设备上的文件与本地不一致: %v", name, err)
+	}
+
+	// 替换文件并删除旧版本
+	hashes := s.remoteHashes[remoteDir]
+	cmd := fmt.Sprintf("mv -f %s %s/%s", tmpFile, remoteDir, name)
+	if oldName != "" {
+		cmd += fmt.Sprintf(" && rm -f %s/%s", remoteDir, oldName)
+		delete(hashes, oldName)
+	}
+	if _, err := s.RunAndWaitCommand(cmd); err != nil {
+		return err
+	}
+
+	hashes[name] = hash
+	s.changed[remoteDir]++
+	return nil
+}
+
+// uploadWithPatch 上传文件到设备，本地存档中有设备上的旧版本时只上传补丁并用bspatch还原
+func (s *fileSyncer) uploadWithPatch(localFile, remoteDir, oldName, remoteFile, oldHash string) error {
+	name := filepath.Base(localFile)
+	archiveFile := filepath.Join(s.archiveDir, oldName)
+
+	if s.archiveDir == "" || oldName == "" || !s.canPatch(archiveFile, oldHash) {
+		return s.UploadFile(localFile, remoteFile)
+	}
+
+	oldData, err := os.ReadFile(archiveFile)
+	if err != nil {
+		return s.UploadFile(localFile, remoteFile)
+	}
+	newData, err := os.ReadFile(localFile)
+	if err != nil {
+		return err
+	}
+
+	patch := utils.Bsdiff(oldData, newData)
+	patchFile := filepath.Join(s.tempDir, name+".patch")
+	if err := utils.WriteFile(patchFile, patch); err != nil {
+		return err
+	}
+	s.AppendOutput(fmt.Sprintf("`%s` -> `%s` 补丁大小: %d 字节 (完整文件: %d 字节)", oldName, name, len(patch), len(newData)))
+
+	remotePatch := remoteFile + ".patch"
+	if err := s.UploadFile(patchFile, remotePatch); err != nil {
+		return err
+	}
+	_, err = s.RunAndWaitCommand(fmt.Sprintf("bspatch %s/%s %s %s", remoteDir, oldName, remoteFile, remotePatch))
+	return err
+}
+
+// canPatch 检查本地存档的旧版本与设备上的文件一致，且设备已安装bspatch
+func (s *fileSyncer) canPatch(archiveFile, remoteHash string) bool {
+	hash, err := localHashCache.FileSHA256(archiveFile)
+	if err != nil || hash != remoteHash {
+		return false
+	}
+
+	_, err = s.RunQuietCommand("command -v bspatch")
+	return err == nil
+}
+
+// hashes 获取设备目录下所有文件的sha256，目录不存在时创建
+func (s *fileSyncer) hashes(remoteDir string) (map[string]string, error) {
+	if hashes, ok := s.remoteHashes[remoteDir]; ok {
+		return hashes, nil
+	}
+
+	hashes := make(map[string]string)
+	if _, err := s.RunQuietCommand("test -d " + remoteDir); err != nil {
+		if _, err := s.RunAndWaitCommand("mkdir -p " + remoteDir); err != nil {
+			return nil, err
+		}
+		s.remoteHashes[remoteDir] = hashes
+		return hashes, nil
+	}
+
+	output, err := s.RunQuietCommand(fmt.Sprintf("cd %s && find . -maxdepth 1 -type f -exec sha256sum {} +", remoteDir))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		hashes[strings.TrimPrefix(fields[1], "./")] = fields[0]
+	}
+
+	s.remoteHashes[remoteDir] = hashes
+	return hashes, nil
+}
+
+// syncSetting 同步主机上的设备配置，配置在内存中解密后直接上传
+func (s *fileSyncer) syncSetting(ver, devSN, remoteFile string) error {
+	if !SettingExists(ver, devSN) {
+		s.AppendOutput("未找到初始配置文件，跳过: " + SettingPath(ver, devSN))
+		return nil
+	}
+
+	data, err := ReadSetting(ver, devSN)
+	if err != nil {
+		return err
+	}
+
+	remoteDir, name := path.Dir(remoteFile), path.Base(remoteFile)
+	hashes, err := s.hashes(remoteDir)
+	if err != nil {
+		return err
+	}
+
+	hash := fmt.Sprintf("%x", sha256.Sum256(data))
+	if hashes[name] == hash {
+		s.AppendOutput(fmt.Sprintf("`%s`无变化，跳过", remoteFile))
+		return nil
+	}
+
+	tmpFile := fmt.Sprintf("%s/%s", remoteSyncDir, name)
+	if err := s.UploadContent(data, tmpFile); err != nil {
+		return err
+	}
+	return s.install(tmpFile, remoteDir, name, "", hash)
+}
+
+// syncFrpc 同步frpc程序及生成的配置
+func (s *fileSyncer) syncFrpc(configs *ComponentConfigs) error {
+	if _, err := s.syncFile(filepath.Join(localFrpcDir, "frpc"), remoteFrpcDir); err != nil {
+		return err
+	}
+
+	file, err := s.stage("frpc.toml", configs.Frpc)
+	if err != nil {
+		return err
+	}
+	if _, err = s.syncFile(file, remoteFrpcDir); err != nil {
+		return err
+	}
+
+	if s.changed[remoteFrpcDir] > 0 {
+		_, err = s.RunAndWaitCommand(fmt.Sprintf("chmod -R 755 %s && systemctl restart frpc", remoteFrpcDir))
+	}
+	return err
+}
+
+// syncConfigFiles 同步渲染后的组件配置文件
+func (s *fileSyncer) syncConfigFiles(configs *ComponentConfigs, remoteDir string) error {
+	names := make([]string, 0, len(configs.Files))
+	for name := range configs.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		file, err := s.stage(name, configs.Files[name])
+		if err != nil {
+			return err
+		}
+		if _, err = s.syncFile(file, remoteDir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SyncFirmware 增量同步，只传输设备上缺失或内容不同的文件
+func (v *V3) SyncFirmware(devSN string) {
+	runFlashTask(v, v.window, &v.flashStatus, devSN, "确认增量同步", fmt.Sprintf("您确定要增量同步 %s 吗？", devSN), "增量同步", func() error {
+		v.AppendOutput("开始增量同步v3程序，执行过程请勿关闭程序!")
+		return v.syncFirmware(devSN)
+	})
+}
+
+func (v *V3) syncFirmware(devSN string) error {
+	if _, err := v.RunQuietCommand(fmt.Sprintf("test -d %s/bin", v3RootDir)); err != nil {
+		v.AppendOutput("设备上未安装v3程序，执行完整刷写")
+		return v.flash(devSN)
+	}
+
+	s := newFileSyncer(v, v.ArchiveDir, v.DownloadTempDir)
+	if err := s.begin(); err != nil {
+		return err
+	}
+	defer s.end()
+
+	binDir := v3RootDir + "/bin"
+	if err := s.syncDir(v.LocalDir, binDir); err != nil {
+		return err
+	}
+
+	configs, err := renderComponentConfigs(v, "v3", devSN)
+	if err != nil {
+		return err
+	}
+	if err := s.syncConfigFiles(configs, v3RootDir+"/data/config"); err != nil {
+		return err
+	}
+
+	if err := s.syncSetting("v3", devSN, v3RemoteSetting); err != nil {
+		return err
+	}
+
+	if s.total() > 0 {
+		if _, err := v.RunAndWaitCommand(fmt.Sprintf("chmod -R 755 %s && systemctl restart cgKeepalive", binDir)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.syncFrpc(configs); err != nil {
+		return err
+	}
+
+	// 设备未安装bsdiff时补装
+	if _, err := v.RunQuietCommand("dpkg -s bsdiff"); err != nil {
+		if err := v.UploadFile(localBsdiffDeb, remoteSyncDir+"/bsdiff.deb"); err != nil {
+			return err
+		}
+		if _, err := v.RunAndWaitCommand(fmt.Sprintf("dpkg -i %s/bsdiff.deb", remoteSyncDir)); err != nil {
+			return err
+		}
+	}
+
+	v.AppendOutput(fmt.Sprintf("增量同步完成，共更新 %d 个文件", s.total()))
+	return nil
+}
+
+// SyncFirmware 增量同步，只传输设备上缺失或内容不同的文件
+func (v *V2) SyncFirmware(devSN string) {
+	runFlashTask(v, v.window, &v.flashStatus, devSN, "确认增量同步", fmt.Sprintf("您确定要增量同步 %s 吗？", devSN), "增量同步", func() error {
+		v.AppendOutput("开始增量同步v2程序，执行过程请勿关闭程序!")
+		return v.syncFirmware(devSN)
+	})
+}
+
+func (v *V2) syncFirmware(devSN string) error {
+	if _, err := v.RunQuietCommand(fmt.Sprintf("test -d %s/bin", v2RootDir)); err != nil {
+		v.AppendOutput("设备上未安装v2程序，执行完整刷写")
+		return v.flash(devSN)
+	}
+
+	// v2程序文件不带版本号，不使用差分补丁
+	s := newFileSyncer(v, "", v.DownloadTempDir)
+	if err := s.begin(); err != nil {
+		return err
+	}
+	defer s.end()
+
+	binDir := v2RootDir + "/bin"
+	if err := s.syncDir(filepath.Join(v.LocalDir, "bin"), binDir); err != nil {
+		return err
+	}
+	configs, err := renderComponentConfigs(v, "v2", devSN)
+	if err != nil {
+		return err
+	}
+	if err := s.syncConfigFiles(configs, v2RootDir+"/data/config"); err != nil {
+		return err
+	}
+	if err := s.syncSetting("v2", devSN, v2RemoteSetting); err != nil {
+		return err
+	}
+
+	if s.total() > 0 {
+		if _, err := v.RunAndWaitCommand(fmt.Sprintf("chmod -R 755 %s && systemctl restart cgCollector cgUpdater", binDir)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.syncFrpc(configs); err != nil {
+		return err
+	}
+
+	v.AppendOutput(fmt.Sprintf("增量同步完成，共更新 %d 个文件", s.total()))
+	return nil
+}
@@ -0,0 +1,91 @@
+package version
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const settingTemplateDir = "templates/v3" // v3设备配置模板目录，每个项目一个 <name>.json
+
+// SettingTemplateData 渲染配置模板时可用的变量，如 {{.SN}}
+type SettingTemplateData struct {
+	SN   string // 设备SN
+	Date string // 生成日期
+}
+
+// ListSettingTemplates 列出可用的v3配置模板名称
+func ListSettingTemplates() ([]string, error) {
+	entries, err := os.ReadDir(settingTemplateDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// RenderSettingTemplate 使用设备SN渲染配置模板，SN原样写入JSON，需先校验格式
+func RenderSettingTemplate(name, sn string) (*V3Setting, error) {
+	if err := ValidateSN(sn); err != nil {
+		return nil, err
+	}
+
+	tmplPath := filepath.Join(settingTemplateDir, name+".json")
+	tmpl, err := template.New(filepath.Base(tmplPath)).Option("missingkey=error").ParseFiles(tmplPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置模板失败: %v", err)
+	}
+
+	var buf bytes.Buffer
+	data := SettingTemplateData{SN: sn, Date: time.Now().Format("2006-01-02")}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("渲染配置模板失败: %v", err)
+	}
+
+	return ParseV3Setting(buf.Bytes())
+}
+
+// CreateSettingFromTemplate 根据模板为新设备生成v3配置，并标记为本地编写
+// overwrite为false时，已存在的配置不会被覆盖
+func CreateSettingFromTemplate(name, sn string, overwrite bool) (*V3Setting, error) {
+	if !overwrite && SettingExists("v3", sn) {
+		return nil, fmt.Errorf("设备 %s 的配置已存在", sn)
+	}
+
+	setting, err := RenderSettingTemplate(name, sn)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := setting.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := WriteSetting("v3", sn, data); err != nil {
+		return nil, fmt.Errorf("保存配置失败: %v", err)
+	}
+
+	meta := &SettingMeta{Source: SettingSourceTemplate, Template: name}
+	if err := WriteSettingMeta("v3", sn, meta); err != nil {
+		return nil, fmt.Errorf("保存配置来源信息失败: %v", err)
+	}
+
+	return setting, nil
+}
@@ -0,0 +1,37 @@
+package version
+
+import (
+	"os"
+	"testing"
+)
+
+// SN原样写入JSON模板，包含引号等字符的SN应在渲染前拒绝
+func TestRenderSettingTemplateSN(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	tests := []struct {
+		sn      string
+		wantErr bool
+	}{
+		{"KBD0921000129", false},
+		{"7808001762308110045", false},
+		{"2C-2021_0001", false},
+		{"", true},
+		{`KBD09","mqtt_pwd":"x`, true},
+		{"../KBD0921000129", true},
+		{"KBD 0921", true},
+	}
+	for _, tt := range tests {
+		_, err := RenderSettingTemplate("example", tt.sn)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("RenderSettingTemplate(%q) 错误 = %v, 期望错误: %v", tt.sn, err, tt.wantErr)
+		}
+	}
+}
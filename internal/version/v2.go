@@ -2,6 +2,8 @@ package version
 
 import (
 	"EMInit/pkg/utils"
+	"crypto/md5"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"fyne.io/fyne/v2"
@@ -10,7 +12,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"sync/atomic"
+	"strings"
 	"time"
 )
 
@@ -162,139 +164,142 @@ func (v *V2) handleFirmwareVersion(firmware *Firmware) error {
 	return nil
 }
 
-func (v *V2) packageFirmware(needRePackage bool) (err error) {
-	v.AppendOutput("开始打包固件...")
-
-	tarFile := "v2_init.tar.gz"
-	if needRePackage {
-		os.Remove(tarFile)
-		v.AppendOutput("删除旧固件重新打包...")
-	}
-
-	if _, err := os.Stat(tarFile); err == nil {
-		v.AppendOutput("固件已存在，无需再次打包!")
-		time.Sleep(3 * time.Second)
-		return nil
-	}
-
-	// 打包文件
-	dirs := []string{
-		"v2_install",
-		"share",
+func (v *V2) DownloadConfig(sn string) error {
+	data, meta, err := v.FetchConfig(sn)
+	if err != nil {
+		return err
 	}
 
-	if err = utils.CreateTarGz(tarFile, dirs); err != nil {
+	if err = WriteSetting("v2", sn, data); err != nil {
 		return err
 	}
-
-	return nil
+	return WriteSettingMeta("v2", sn, meta)
 }
 
-func (v *V2) DownloadConfig(sn string) error {
+// FetchConfig 从ERP平台获取设备配置并校验，不保存到主机
+func (v *V2) FetchConfig(sn string) ([]byte, *SettingMeta, error) {
 	url := fmt.Sprintf("%s/%s", v.InitConfigUrl, sn)
 
 	response, err := httpClient.Get(url)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer response.Body.Close()
 
 	v.AppendOutput(fmt.Sprintf("开始下载配置文件，请求URL: %s", url))
 
 	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("响应码：%v", response.StatusCode)
+		return nil, nil, &HTTPError{StatusCode: response.StatusCode}
 	}
 
 	var setting struct {
-		Success int    `json:"success"`
-		Msg     string `json:"msg"`
+		Success int             `json:"success"`
+		Msg     string          `json:"msg"`
+		Md5     string          `json:"md5"`
+		Data    json.RawMessage `json:"data"`
 	}
 	data, err := io.ReadAll(response.Body)
-	err = json.Unmarshal(data, &setting)
-	if err != nil || setting.Success != 1 {
-		return fmt.Errorf("未获取到设备相关配置文件: %s", setting.Msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = json.Unmarshal(data, &setting); err != nil {
+		return nil, nil, fmt.Errorf("未获取到设备相关配置文件: %v", err)
+	}
+	if setting.Success != 1 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotRegistered, setting.Msg)
 	}
 
-	_ = os.MkdirAll("setting/v2", 0755)
-	err = utils.WriteFile(fmt.Sprintf("setting/v2/%s.json", sn), data)
-	return err
-}
+	meta := &SettingMeta{
+		Source:    SettingSourceErp,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Url:       url,
+		ServerMd5: setting.Md5,
+		Md5Check:  Md5CheckNotProvided,
+		Decrypt:   DecryptNone,
+		Sha256:    fmt.Sprintf("%x", sha256.Sum256(data)),
+	}
 
-func (v *V2) FlashFirmware(devSN string, rePackage bool) {
-	if devSN == "" {
-		dialog.ShowInformation("警告", "设备SN不能为空!", v.window)
-		return
+	// md5为data字段原始内容的MD5
+	if setting.Md5 != "" {
+		actual := fmt.Sprintf("%x", md5.Sum(setting.Data))
+		if !strings.EqualFold(actual, setting.Md5) {
+			return nil, nil, &ChecksumError{Expected: setting.Md5, Actual: actual}
+		}
+		meta.Md5Check = Md5CheckPassed
 	}
 
-	if !atomic.CompareAndSwapInt32(&v.flashStatus, 0, 1) {
-		v.AppendOutput("刷写正在进行中!")
-		dialog.ShowInformation("警告", "刷写正在进行中!", v.window)
-		return
+	parsed, err := ParseV2Setting(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if errs := parsed.Validate(); len(errs) > 0 {
+		return nil, nil, fmt.Errorf("配置内容无效: %v", joinErrors(errs))
 	}
 
-	conf := dialog.NewConfirm("确认初始化", fmt.Sprintf("您确定要初始化 %s 吗？", devSN), func(confirmed bool) {
-		if !confirmed {
-			atomic.StoreInt32(&v.flashStatus, 0)
-			return
-		}
+	return data, meta, nil
+}
 
+func (v *V2) FlashFirmware(devSN string) {
+	runFlashTask(v, v.window, &v.flashStatus, devSN, "确认初始化", fmt.Sprintf("您确定要初始化 %s 吗？", devSN), "刷写固件", func() error {
 		v.AppendOutput("开始刷写v2程序，执行过程请勿关闭程序!")
+		return v.flash(devSN)
+	})
+}
+
+// flash 打包固件并流式传输到设备，然后执行初始化脚本
+func (v *V2) flash(devSN string) (err error) {
+	// 先检查初始配置，按策略中止时不改动设备
+	if err = checkSettingBeforeFlash(v, "v2", devSN); err != nil {
+		return err
+	}
 
-		go func() {
-			var err error
+	// 先生成组件配置，校验失败时不改动设备
+	configs, err := renderComponentConfigs(v, "v2", devSN)
+	if err != nil {
+		return err
+	}
 
-			if err = v.packageFirmware(rePackage); err != nil {
-				v.AppendOutput("打包固件失败: " + err.Error())
-				return
-			}
-			v.AppendOutput("打包固件完成！")
-
-			defer func() {
-				atomic.StoreInt32(&v.flashStatus, 0)
-				if err != nil {
-					v.AppendOutput("刷写固件失败: " + err.Error())
-				} else {
-					v.AppendOutput("刷写固件成功!")
-				}
-			}()
-
-			// 删除临时目录
-			if _, err = v.RunAndWaitCommand("rm -rf /tmpcf"); err != nil {
-				return
-			}
-			// 创建临时目录
-			if _, err = v.RunAndWaitCommand("mkdir -p /tmpcf"); err != nil {
-				return
-			}
+	// 删除临时目录
+	if _, err = v.RunAndWaitCommand("rm -rf /tmpcf"); err != nil {
+		return err
+	}
+	// 创建临时目录
+	if _, err = v.RunAndWaitCommand("mkdir -p /tmpcf"); err != nil {
+		return err
+	}
 
-			// 传输文件
-			if err = v.UploadFile("v2_init.tar.gz", "/tmpcf/v2_init.tar.gz"); err != nil {
-				return
-			}
-			if err = v.UploadFile("v2_install.sh", "/tmpcf/v2_install.sh"); err != nil {
-				return
-			}
+	// 边打包边传输，在设备上直接解压
+	v.AppendOutput("开始打包并传输固件...")
+	err = v.StreamToCommand("tar -zxf - -C /tmpcf", func(w io.Writer) error {
+		return utils.WriteTarGzWithOverrides(w, []string{"v2_install", "share"}, configs.TarOverrides("v2"))
+	})
+	if err != nil {
+		return fmt.Errorf("传输固件失败: %v", err)
+	}
+	v.AppendOutput("固件传输完成！")
 
-			// 执行脚本
-			if _, err = v.RunAndWaitCommand(fmt.Sprintf("cd /tmpcf && sh v2_install.sh %s", devSN)); err != nil {
-				return
-			}
+	// 传输脚本
+	if err = v.UploadFile("v2_install.sh", "/tmpcf/v2_install.sh"); err != nil {
+		return err
+	}
 
-			// 尝试将初始配置文件传到设备
-			if err = v.UploadFile(fmt.Sprintf("setting/v2/%s.json", devSN), "/datas/cf_go_v2/data/init/setting.json"); err == nil {
-				v.AppendOutput("初始配置文件上传成功!")
-			} else {
-				v.AppendOutput("初始配置文件上传失败: " + err.Error())
-				err = nil
-				v.AppendOutput("设备插卡联网后，将自动更新初始配置文件!")
-			}
+	// 执行脚本
+	if _, err = v.RunAndWaitCommand(fmt.Sprintf("cd /tmpcf && sh v2_install.sh %s", devSN)); err != nil {
+		return err
+	}
 
-		}()
+	// 尝试将初始配置文件传到设备
+	if err = uploadSetting(v, "v2", devSN, v2RemoteSetting); err == nil {
+		v.AppendOutput("初始配置文件上传成功!")
+	} else {
+		v.AppendOutput("初始配置文件上传失败: " + err.Error())
+		v.AppendOutput("设备插卡联网后，将自动更新初始配置文件!")
+	}
 
-	}, v.window)
+	return nil
+}
 
-	conf.SetConfirmText("是")
-	conf.SetDismissText("否")
-	conf.Show()
-	return
+// DeltaUpdate v2程序没有按版本命名的组件文件，不支持差分更新
+func (v *V2) DeltaUpdate(devSN string) {
+	dialog.ShowInformation("提示", "v2版本不支持差分更新，请使用完整刷写!", v.window)
 }
@@ -2,25 +2,25 @@ package version
 
 import (
 	"EMInit/pkg/utils"
+	"crypto/md5"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/dialog"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
-	"sync/atomic"
+	"strings"
 	"time"
-
-	"github.com/gogf/gf/v2/util/gconv"
 )
 
 type V3 struct {
 	IFlashTool
 
 	LocalDir        string // 本地固件目录
+	ArchiveDir      string // 本地旧版本固件存档目录，用于生成差分补丁
 	RemoteUrl       string // 获取固件版本信息URL
 	InitConfigUrl   string // 初始化配置文件URL
 	DownloadTempDir string // 下载固件临时目录
@@ -32,6 +32,7 @@ type V3 struct {
 func NewV3(flashTool IFlashTool, window fyne.Window) *V3 {
 	return &V3{
 		LocalDir:        "./v3_install/bin/",
+		ArchiveDir:      "./v3_archive/",
 		RemoteUrl:       "https://erp.2cifang.cn/api/api/box/pkg?sn=arm7v3",
 		InitConfigUrl:   "https://erp.2cifang.cn/api/box/erp",
 		DownloadTempDir: "./tmp_v3",
@@ -170,15 +171,20 @@ func (v *V3) handleFirmwareVersion(firmware *Firmware) error {
 		return fmt.Errorf("重命名`%s`失败: %v", firmware.name, err)
 	}
 
-	// 删除本地旧固件
+	// 将本地旧固件移入存档目录，用于生成差分补丁
+	var oldFile string
 	switch firmware.name {
 	case "cgManager":
-		if err := os.RemoveAll(filepath.Join(v.LocalDir, fmt.Sprintf("cgManager_main_%d_app", firmware.localVersion))); err != nil {
-			return fmt.Errorf("删除旧固件失败: %v", err)
-		}
+		oldFile = fmt.Sprintf("cgManager_main_%d_app", firmware.localVersion)
 	default:
-		if err := os.RemoveAll(filepath.Join(v.LocalDir, fmt.Sprintf("%s_%d_parent", firmware.name, firmware.localVersion))); err != nil {
-			return fmt.Errorf("删除旧固件失败: %v", err)
+		oldFile = fmt.Sprintf("%s_%d_parent", firmware.name, firmware.localVersion)
+	}
+	if _, err := os.Stat(filepath.Join(v.LocalDir, oldFile)); err == nil {
+		if err := os.MkdirAll(v.ArchiveDir, 0755); err != nil {
+			return fmt.Errorf("创建存档目录失败: %v", err)
+		}
+		if err := os.Rename(filepath.Join(v.LocalDir, oldFile), filepath.Join(v.ArchiveDir, oldFile)); err != nil {
+			return fmt.Errorf("存档旧固件失败: %v", err)
 		}
 	}
 
@@ -187,185 +193,208 @@ func (v *V3) handleFirmwareVersion(firmware *Firmware) error {
 	return nil
 }
 
-// PackageFirmware 打包固件
-func (v *V3) packageFirmware(needRePackage bool) (err error) {
-	v.AppendOutput("开始打包固件...")
-	tarFile := "v3_init.tar.gz"
+func (v *V3) DownloadConfig(sn string) error {
+	data, meta, err := v.FetchConfig(sn)
+	if err != nil {
+		return err
+	}
 
-	if needRePackage {
-		os.Remove(tarFile)
-		v.AppendOutput("删除旧固件重新打包...")
+	// 本地编写的配置被ERP平台配置覆盖前先备份，便于核对
+	if meta, _ := ReadSettingMeta("v3", sn); meta.LocallyAuthored() && SettingExists("v3", sn) {
+		backupPath, err := BackupSetting("v3", sn, "local")
+		if err != nil {
+			return fmt.Errorf("备份本地配置失败: %v", err)
+		}
+		v.AppendOutput(fmt.Sprintf("设备 %s 的本地配置(%s)已备份到 %s，将使用ERP平台配置", sn, meta.Describe(), backupPath))
 	}
 
-	// 检查固件是否已经存在
-	if _, err := os.Stat(tarFile); err == nil {
-		v.AppendOutput("固件已存在，无需再次打包!")
-		time.Sleep(3 * time.Second)
-		return nil
+	if err = WriteSetting("v3", sn, data); err != nil {
+		return err
+	}
+	if err = WriteSettingMeta("v3", sn, meta); err != nil {
+		return err
 	}
 
-	// 打包文件
-	dirs := []string{
-		"v3_install",
-		"share",
+	var info struct {
+		Erp struct {
+			MqttIp   string `json:"mqtt_ip"`
+			MqttPort int    `json:"mqtt_port"`
+			MqttUser string `json:"mqtt_user"`
+			MqttPwd  string `json:"mqtt_pwd"`
+			Tls      int    `json:"tls"`
+			Ca       string `json:"ca"`
+			Encrypt  int    `json:"encrypt"`
+		} `json:"erp"`
+		Pro struct {
+			Url      string `json:"url"`
+			MqttIp   string `json:"mqtt_ip"`
+			MqttPort int    `json:"mqtt_port"`
+			MqttUser string `json:"mqtt_user"`
+			MqttPwd  string `json:"mqtt_pwd"`
+			Tls      int    `json:"tls"`
+			Ca       string `json:"ca"`
+		} `json:"pro"`
+		App []string `json:"app"`
 	}
 
-	if err = utils.CreateTarGz(tarFile, dirs); err != nil {
+	if err = json.Unmarshal(data, &info); err != nil {
 		return err
 	}
+	jsonContent, err := json.Marshal(info)
+	v.AppendOutput(string(jsonContent))
+
+	return err
 
-	return nil
 }
 
-func (v *V3) DownloadConfig(sn string) error {
+// FetchConfig 从ERP平台获取设备配置，解密并校验，不保存到主机
+func (v *V3) FetchConfig(sn string) ([]byte, *SettingMeta, error) {
 	url := fmt.Sprintf("%s/%s", v.InitConfigUrl, sn)
 
 	v.AppendOutput(fmt.Sprintf("开始下载配置文件，请求URL: %s", url))
 	response, err := httpClient.Get(url)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("响应码：%v", response.StatusCode)
+		return nil, nil, &HTTPError{StatusCode: response.StatusCode}
 	}
 
 	var httpResponse struct {
 		Success int    `json:"success"`
 		Msg     string `json:"msg"`
+		Md5     string `json:"md5"`
 		Data    string `json:"data"`
 		Encrypt string `json:"encrypt"`
 	}
 	data, err := io.ReadAll(response.Body)
-	err = json.Unmarshal(data, &httpResponse)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	if err = json.Unmarshal(data, &httpResponse); err != nil {
+		return nil, nil, fmt.Errorf("解析配置响应失败: %v", err)
 	}
 
 	if httpResponse.Success != 1 {
-		return fmt.Errorf("%v", httpResponse.Msg)
+		return nil, nil, fmt.Errorf("%w: %v", ErrNotRegistered, httpResponse.Msg)
+	}
+
+	meta := &SettingMeta{
+		Source:    SettingSourceErp,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Url:       url,
+		ServerMd5: httpResponse.Md5,
+		Md5Check:  Md5CheckNotProvided,
+		Decrypt:   DecryptNone,
+	}
+
+	// md5为data字段(解密前)内容的MD5
+	if httpResponse.Md5 != "" {
+		actual := fmt.Sprintf("%x", md5.Sum([]byte(httpResponse.Data)))
+		if !strings.EqualFold(actual, httpResponse.Md5) {
+			return nil, nil, &ChecksumError{Expected: httpResponse.Md5, Actual: actual}
+		}
+		meta.Md5Check = Md5CheckPassed
 	}
 
 	// 数据解密
 	if len(httpResponse.Encrypt) > 0 {
 		decrypt, err := utils.DecryptAES256(httpResponse.Data, fmt.Sprintf("%s2cifang", sn), httpResponse.Encrypt)
 		if err != nil {
-			return err
+			return nil, nil, &DecryptError{Err: err}
 		}
 		httpResponse.Data = decrypt
+		meta.Decrypt = DecryptOK
 	}
 
-	_ = os.MkdirAll("setting/v3", 0755)
-	err = utils.WriteFile(fmt.Sprintf("setting/v3/%s.json", sn), []byte(httpResponse.Data))
-
-	var info struct {
-		Erp struct {
-			MqttIp   string `json:"mqtt_ip"`
-			MqttPort int    `json:"mqtt_port"`
-			MqttUser string `json:"mqtt_user"`
-			MqttPwd  string `json:"mqtt_pwd"`
-			Tls      int    `json:"tls"`
-			Ca       string `json:"ca"`
-			Encrypt  int    `json:"encrypt"`
-		} `json:"erp"`
-		Pro struct {
-			Url      string `json:"url"`
-			MqttIp   string `json:"mqtt_ip"`
-			MqttPort int    `json:"mqtt_port"`
-			MqttUser string `json:"mqtt_user"`
-			MqttPwd  string `json:"mqtt_pwd"`
-			Tls      int    `json:"tls"`
-			Ca       string `json:"ca"`
-		} `json:"pro"`
-		App []string `json:"app"`
+	// 严格校验配置内容
+	setting, err := ParseV3Setting([]byte(httpResponse.Data))
+	if err != nil {
+		if meta.Decrypt == DecryptOK {
+			return nil, nil, &DecryptError{Err: err}
+		}
+		return nil, nil, err
+	}
+	// 与v2一致，内容校验不通过时不保存，避免错误配置刷写到设备
+	if errs := setting.Validate(); len(errs) > 0 {
+		return nil, nil, fmt.Errorf("配置内容无效: %v", joinErrors(errs))
 	}
 
-	gconv.Struct(httpResponse.Data, &info)
-	jsonContent, err := json.Marshal(info)
-	v.AppendOutput(string(jsonContent))
-
-	return err
-
+	meta.Sha256 = fmt.Sprintf("%x", sha256.Sum256([]byte(httpResponse.Data)))
+	return []byte(httpResponse.Data), meta, nil
 }
 
 // FlashFirmware 刷写固件
-func (v *V3) FlashFirmware(devSN string, rePackage bool) {
-	if devSN == "" {
-		dialog.ShowInformation("警告", "设备SN不能为空!", v.window)
-		return
-	}
-
-	if !atomic.CompareAndSwapInt32(&v.flashStatus, 0, 1) {
-		v.AppendOutput("刷写正在进行中!")
-		dialog.ShowInformation("警告", "刷写正在进行中!", v.window)
-		return
-	}
-
-	conf := dialog.NewConfirm("确认初始化", fmt.Sprintf("您确定要初始化 %s 吗？", devSN), func(confirmed bool) {
-		if !confirmed {
-			atomic.StoreInt32(&v.flashStatus, 0)
-			return
-		}
-
+func (v *V3) FlashFirmware(devSN string) {
+	runFlashTask(v, v.window, &v.flashStatus, devSN, "确认初始化", fmt.Sprintf("您确定要初始化 %s 吗？", devSN), "刷写固件", func() error {
 		v.AppendOutput("开始刷写v3程序，执行过程请勿关闭程序!")
+		return v.flash(devSN)
+	})
+}
 
-		go func() {
-			var err error
+// flash 打包固件并流式传输到设备，然后执行初始化脚本
+func (v *V3) flash(devSN string) error {
+	configs, err := v.prepareFlash(devSN)
+	if err != nil {
+		return err
+	}
+	return v.install(devSN, configs)
+}
 
-			if err = v.packageFirmware(rePackage); err != nil {
-				v.AppendOutput("打包固件失败: " + err.Error())
-				return
-			}
-			v.AppendOutput("打包固件完成！")
-
-			defer func() {
-				atomic.StoreInt32(&v.flashStatus, 0)
-				if err != nil {
-					v.AppendOutput("刷写固件失败: " + err.Error())
-				} else {
-					v.AppendOutput("刷写固件成功!")
-				}
-			}()
-
-			// 删除临时目录
-			if _, err = v.RunAndWaitCommand("rm -rf /tmpcf"); err != nil {
-				return
-			}
+// prepareFlash 刷写前检查初始配置并生成组件配置，失败时不改动设备
+func (v *V3) prepareFlash(devSN string) (*ComponentConfigs, error) {
+	// 按策略中止
+	if err := checkSettingBeforeFlash(v, "v3", devSN); err != nil {
+		return nil, err
+	}
+	// 组件配置校验失败时中止
+	return renderComponentConfigs(v, "v3", devSN)
+}
 
-			// 创建临时目录
-			if _, err = v.RunAndWaitCommand("mkdir -p /tmpcf"); err != nil {
-				return
-			}
+// install 传输固件、执行安装脚本并上传初始配置
+func (v *V3) install(devSN string, configs *ComponentConfigs) (err error) {
+	// 删除临时目录
+	if _, err = v.RunAndWaitCommand("rm -rf /tmpcf"); err != nil {
+		return err
+	}
 
-			// 传输文件
-			if err = v.UploadFile("v3_init.tar.gz", "/tmpcf/v3_init.tar.gz"); err != nil {
-				return
-			}
+	// 创建临时目录
+	if _, err = v.RunAndWaitCommand("mkdir -p /tmpcf"); err != nil {
+		return err
+	}
 
-			if err = v.UploadFile("v3_install.sh", "/tmpcf/v3_install.sh"); err != nil {
-				return
-			}
+	// 边打包边传输，在设备上直接解压
+	v.AppendOutput("开始打包并传输固件...")
+	err = v.StreamToCommand("tar -zxf - -C /tmpcf", func(w io.Writer) error {
+		return utils.WriteTarGzWithOverrides(w, []string{"v3_install", "share"}, configs.TarOverrides("v3"))
+	})
+	if err != nil {
+		return fmt.Errorf("传输固件失败: %v", err)
+	}
+	v.AppendOutput("固件传输完成！")
 
-			// 执行脚本
-			if _, err = v.RunAndWaitCommand(fmt.Sprintf("cd /tmpcf && sh v3_install.sh %s", devSN)); err != nil {
-				return
-			}
+	// 传输脚本
+	if err = v.UploadFile("v3_install.sh", "/tmpcf/v3_install.sh"); err != nil {
+		return err
+	}
 
-			// 尝试将初始配置文件传到设备
-			if err = v.UploadFile(fmt.Sprintf("setting/v3/%s.json", devSN), "/datas/cf_go_v3/data/cache/settings.json"); err == nil {
-				v.AppendOutput("初始配置文件上传成功!")
-			} else {
-				v.AppendOutput("初始配置文件上传失败: " + err.Error())
-				err = nil
-				v.AppendOutput("设备插卡联网后，将自动更新初始配置文件!")
-			}
-		}()
+	// 执行脚本
+	if _, err = v.RunAndWaitCommand(fmt.Sprintf("cd /tmpcf && sh v3_install.sh %s", devSN)); err != nil {
+		return err
+	}
 
-	}, v.window)
+	// 尝试将初始配置文件传到设备
+	if meta, _ := ReadSettingMeta("v3", devSN); meta.LocallyAuthored() {
+		v.AppendOutput(fmt.Sprintf("注意: 设备 %s 的初始配置为本地配置(%s)，设备在ERP平台注册后请重新下载配置核对", devSN, meta.Describe()))
+	}
+	if err = uploadSetting(v, "v3", devSN, v3RemoteSetting); err == nil {
+		v.AppendOutput("初始配置文件上传成功!")
+	} else {
+		v.AppendOutput("初始配置文件上传失败: " + err.Error())
+		v.AppendOutput("设备插卡联网后，将自动更新初始配置文件!")
+	}
 
-	conf.SetConfirmText("是")
-	conf.SetDismissText("否")
-	conf.Show()
-	return
+	return nil
 }
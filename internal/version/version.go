@@ -2,7 +2,19 @@ package version
 
 import (
 	"crypto/tls"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"io"
 	"net/http"
+	"sync/atomic"
+)
+
+const (
+	v2RootDir       = "/datas/cf_go_v2"                          // 设备上v2程序主目录
+	v3RootDir       = "/datas/cf_go_v3"                          // 设备上v3程序主目录
+	v2RemoteSetting = "/datas/cf_go_v2/data/init/setting.json"   // 设备上v2配置文件
+	v3RemoteSetting = "/datas/cf_go_v3/data/cache/settings.json" // 设备上v3配置文件
 )
 
 type IFlashTool interface {
@@ -12,15 +24,46 @@ type IFlashTool interface {
 	AppendOutput(message string)
 	// UploadFile 上传文件
 	UploadFile(localPath, remotePath string) error
+	// RunQuietCommand 运行命令并返回标准输出，不输出日志，仅用于只读查询
+	RunQuietCommand(cmd string) (string, error)
+	// StreamToCommand 运行命令，并将 write 生成的数据流式写入命令的标准输入
+	StreamToCommand(cmd string, write func(w io.Writer) error) error
+	// StreamFromCommand 运行命令，并将命令的标准输出流式交给 read 处理，不输出日志，仅用于只读查询
+	StreamFromCommand(cmd string, read func(r io.Reader) error) error
+	// UploadContent 将内存中的内容上传为设备上的文件，不落盘
+	UploadContent(content []byte, remotePath string) error
+}
+
+// IDryRun 预演模式的flashTool实现此接口，预演时不修改主机上的文件
+type IDryRun interface {
+	DryRun() bool
+}
+
+// isDryRun flashTool是否处于预演模式
+func isDryRun(flashTool IFlashTool) bool {
+	d, ok := flashTool.(IDryRun)
+	return ok && d.DryRun()
 }
 
 type IFirmwareVersion interface {
 	// CheckFirmwareVersion 检查固件版本
 	CheckFirmwareVersion() error
 	// FlashFirmware 刷写固件
-	FlashFirmware(devSN string, rePackage bool)
+	FlashFirmware(devSN string)
 	// DownloadConfig 下载网关配置
 	DownloadConfig(sn string) error
+	// DeltaUpdate 差分更新设备程序
+	DeltaUpdate(devSN string)
+	// SyncFirmware 增量同步设备程序
+	SyncFirmware(devSN string)
+	// FetchConfig 从ERP平台获取网关配置并校验，返回配置内容及来源信息，不保存
+	FetchConfig(sn string) ([]byte, *SettingMeta, error)
+	// CompareConfig 对比设备上的配置与ERP配置
+	CompareConfig(devSN string, fromErp bool) (*ConfigCompare, error)
+	// PushConfig 将配置推送到设备
+	PushConfig(devSN string, data []byte) error
+	// SaveDeviceConfig 将设备上的配置保存到主机
+	SaveDeviceConfig(devSN string, data []byte) error
 }
 
 type Firmware struct {
@@ -63,3 +106,38 @@ func init() {
 		},
 	}
 }
+
+// runFlashTask 确认后在后台执行刷写类任务，同一时间只允许执行一个
+func runFlashTask(flashTool IFlashTool, window fyne.Window, status *int32, devSN, title, message, name string, task func() error) {
+	if devSN == "" {
+		dialog.ShowInformation("警告", "设备SN不能为空!", window)
+		return
+	}
+
+	if !atomic.CompareAndSwapInt32(status, 0, 1) {
+		flashTool.AppendOutput("刷写正在进行中!")
+		dialog.ShowInformation("警告", "刷写正在进行中!", window)
+		return
+	}
+
+	conf := dialog.NewConfirm(title, message, func(confirmed bool) {
+		if !confirmed {
+			atomic.StoreInt32(status, 0)
+			return
+		}
+
+		go func() {
+			err := task()
+			atomic.StoreInt32(status, 0)
+			if err != nil {
+				flashTool.AppendOutput(fmt.Sprintf("%s失败: %s", name, err.Error()))
+			} else {
+				flashTool.AppendOutput(fmt.Sprintf("%s成功!", name))
+			}
+		}()
+	}, window)
+
+	conf.SetConfirmText("是")
+	conf.SetDismissText("否")
+	conf.Show()
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
+	"golang.org/x/crypto/pbkdf2"
 	"io"
 )
 
@@ -111,3 +112,8 @@ func PKCS5UnPadding(origData []byte) ([]byte, error) {
 
 	return origData[:(length - unPadding)], nil
 }
+
+// DeriveKey 使用PBKDF2从密码派生密钥
+func DeriveKey(password string, salt []byte, iter, keyLen int) []byte {
+	return pbkdf2.Key([]byte(password), salt, iter, keyLen, sha256.New)
+}
@@ -0,0 +1,298 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Bsdiff 生成从旧文件到新文件的二进制补丁，格式与 bsdiff 4.3 (BSDIFF40) 一致，可在设备上使用 bspatch 还原
+func Bsdiff(oldData, newData []byte) []byte {
+	I := qsufsort(oldData)
+
+	var ctrl, diff, extra bytes.Buffer
+	oldSize, newSize := len(oldData), len(newData)
+
+	var scan, pos, length int
+	var lastScan, lastPos, lastOffset int
+	for scan < newSize {
+		oldScore := 0
+
+		scan += length
+		for scsc := scan; scan < newSize; scan++ {
+			pos, length = bsSearch(I, oldData, newData[scan:], 0, oldSize)
+
+			for ; scsc < scan+length; scsc++ {
+				if scsc+lastOffset < oldSize && oldData[scsc+lastOffset] == newData[scsc] {
+					oldScore++
+				}
+			}
+
+			if (length == oldScore && length != 0) || length > oldScore+8 {
+				break
+			}
+
+			if scan+lastOffset < oldSize && oldData[scan+lastOffset] == newData[scan] {
+				oldScore--
+			}
+		}
+
+		if length != oldScore || scan == newSize {
+			// 向前扩展
+			s, sf, lenf := 0, 0, 0
+			for i := 0; lastScan+i < scan && lastPos+i < oldSize; {
+				if oldData[lastPos+i] == newData[lastScan+i] {
+					s++
+				}
+				i++
+				if s*2-i > sf*2-lenf {
+					sf = s
+					lenf = i
+				}
+			}
+
+			// 向后扩展
+			lenb := 0
+			if scan < newSize {
+				s, sb := 0, 0
+				for i := 1; scan >= lastScan+i && pos >= i; i++ {
+					if oldData[pos-i] == newData[scan-i] {
+						s++
+					}
+					if s*2-i > sb*2-lenb {
+						sb = s
+						lenb = i
+					}
+				}
+			}
+
+			// 处理重叠部分
+			if lastScan+lenf > scan-lenb {
+				overlap := (lastScan + lenf) - (scan - lenb)
+				s, ss, lens := 0, 0, 0
+				for i := 0; i < overlap; i++ {
+					if newData[lastScan+lenf-overlap+i] == oldData[lastPos+lenf-overlap+i] {
+						s++
+					}
+					if newData[scan-lenb+i] == oldData[pos-lenb+i] {
+						s--
+					}
+					if s > ss {
+						ss = s
+						lens = i + 1
+					}
+				}
+				lenf += lens - overlap
+				lenb -= lens
+			}
+
+			for i := 0; i < lenf; i++ {
+				diff.WriteByte(newData[lastScan+i] - oldData[lastPos+i])
+			}
+			extraLen := (scan - lenb) - (lastScan + lenf)
+			extra.Write(newData[lastScan+lenf : lastScan+lenf+extraLen])
+
+			ctrl.Write(offtout(lenf))
+			ctrl.Write(offtout(extraLen))
+			ctrl.Write(offtout((pos - lenb) - (lastPos + lenf)))
+
+			lastScan = scan - lenb
+			lastPos = pos - lenb
+			lastOffset = pos - scan
+		}
+	}
+
+	ctrlBz := Bzip2Compress(ctrl.Bytes())
+	diffBz := Bzip2Compress(diff.Bytes())
+	extraBz := Bzip2Compress(extra.Bytes())
+
+	var patch bytes.Buffer
+	patch.WriteString("BSDIFF40")
+	patch.Write(offtout(len(ctrlBz)))
+	patch.Write(offtout(len(diffBz)))
+	patch.Write(offtout(newSize))
+	patch.Write(ctrlBz)
+	patch.Write(diffBz)
+	patch.Write(extraBz)
+
+	return patch.Bytes()
+}
+
+// offtout 按bsdiff格式编码整数(小端，最高位为符号位)
+func offtout(x int) []byte {
+	buf := make([]byte, 8)
+	y := x
+	if y < 0 {
+		y = -y
+	}
+	binary.LittleEndian.PutUint64(buf, uint64(y))
+	if x < 0 {
+		buf[7] |= 0x80
+	}
+	return buf
+}
+
+func matchLen(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// bsSearch 在后缀数组中查找与newData最长匹配的位置
+func bsSearch(I []int32, oldData, newData []byte, st, en int) (int, int) {
+	for en-st >= 2 {
+		x := st + (en-st)/2
+		suffix := oldData[I[x]:]
+		n := min(len(suffix), len(newData))
+		if bytes.Compare(suffix[:n], newData[:n]) < 0 {
+			st = x
+		} else {
+			en = x
+		}
+	}
+
+	x := matchLen(oldData[I[st]:], newData)
+	y := matchLen(oldData[I[en]:], newData)
+	if x > y {
+		return int(I[st]), x
+	}
+	return int(I[en]), y
+}
+
+// qsufsort 生成后缀数组(Larsson-Sadakane算法)
+func qsufsort(data []byte) []int32 {
+	n := len(data)
+	I := make([]int32, n+1)
+	V := make([]int32, n+1)
+
+	var buckets [256]int32
+	for _, c := range data {
+		buckets[c]++
+	}
+	for i := 1; i < 256; i++ {
+		buckets[i] += buckets[i-1]
+	}
+	for i := 255; i > 0; i-- {
+		buckets[i] = buckets[i-1]
+	}
+	buckets[0] = 0
+
+	for i, c := range data {
+		buckets[c]++
+		I[buckets[c]] = int32(i)
+	}
+	I[0] = int32(n)
+	for i, c := range data {
+		V[i] = buckets[c]
+	}
+	V[n] = 0
+	for i := 1; i < 256; i++ {
+		if buckets[i] == buckets[i-1]+1 {
+			I[buckets[i]] = -1
+		}
+	}
+	I[0] = -1
+
+	for h := int32(1); I[0] != -int32(n+1); h += h {
+		var length int32
+		i := int32(0)
+		for i < int32(n+1) {
+			if I[i] < 0 {
+				length -= I[i]
+				i -= I[i]
+			} else {
+				if length != 0 {
+					I[i-length] = -length
+				}
+				length = V[I[i]] + 1 - i
+				split(I, V, i, length, h)
+				i += length
+				length = 0
+			}
+		}
+		if length != 0 {
+			I[i-length] = -length
+		}
+	}
+
+	for i := 0; i < n+1; i++ {
+		I[V[i]] = int32(i)
+	}
+
+	return I
+}
+
+func split(I, V []int32, start, length, h int32) {
+	var i, j, k, x, jj, kk int32
+
+	if length < 16 {
+		for k = start; k < start+length; k += j {
+			j = 1
+			x = V[I[k]+h]
+			for i = 1; k+i < start+length; i++ {
+				if V[I[k+i]+h] < x {
+					x = V[I[k+i]+h]
+					j = 0
+				}
+				if V[I[k+i]+h] == x {
+					I[k+j], I[k+i] = I[k+i], I[k+j]
+					j++
+				}
+			}
+			for i = 0; i < j; i++ {
+				V[I[k+i]] = k + j - 1
+			}
+			if j == 1 {
+				I[k] = -1
+			}
+		}
+		return
+	}
+
+	x = V[I[start+length/2]+h]
+	for i = start; i < start+length; i++ {
+		if V[I[i]+h] < x {
+			jj++
+		}
+		if V[I[i]+h] == x {
+			kk++
+		}
+	}
+	jj += start
+	kk += jj
+
+	i, j, k = start, 0, 0
+	for i < jj {
+		if V[I[i]+h] < x {
+			i++
+		} else if V[I[i]+h] == x {
+			I[i], I[jj+j] = I[jj+j], I[i]
+			j++
+		} else {
+			I[i], I[kk+k] = I[kk+k], I[i]
+			k++
+		}
+	}
+	for jj+j < kk {
+		if V[I[jj+j]+h] == x {
+			j++
+		} else {
+			I[jj+j], I[kk+k] = I[kk+k], I[jj+j]
+			k++
+		}
+	}
+
+	if jj > start {
+		split(I, V, start, jj-start, h)
+	}
+	for i = 0; i < kk-jj; i++ {
+		V[I[jj+i]] = kk - 1
+	}
+	if jj == kk-1 {
+		I[jj] = -1
+	}
+	if start+length > kk {
+		split(I, V, kk, start+length-kk, h)
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// bspatch 按bsdiff 4.3的bspatch应用补丁，用于校验Bsdiff的输出
+func bspatch(oldData, patch []byte) ([]byte, error) {
+	if len(patch) < 32 || string(patch[:8]) != "BSDIFF40" {
+		return nil, errors.New("补丁头错误")
+	}
+	ctrlLen, diffLen, newSize := offtin(patch[8:]), offtin(patch[16:]), offtin(patch[24:])
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || 32+ctrlLen+diffLen > len(patch) {
+		return nil, errors.New("补丁长度错误")
+	}
+
+	body := patch[32:]
+	ctrl := bzip2.NewReader(bytes.NewReader(body[:ctrlLen]))
+	diff := bzip2.NewReader(bytes.NewReader(body[ctrlLen : ctrlLen+diffLen]))
+	extra := bzip2.NewReader(bytes.NewReader(body[ctrlLen+diffLen:]))
+
+	newData := make([]byte, newSize)
+	var oldPos, newPos int
+	buf := make([]byte, 8)
+	for newPos < newSize {
+		var c [3]int
+		for i := range c {
+			if _, err := io.ReadFull(ctrl, buf); err != nil {
+				return nil, fmt.Errorf("读取控制块失败: %v", err)
+			}
+			c[i] = offtin(buf)
+		}
+
+		if c[0] < 0 || newPos+c[0] > newSize {
+			return nil, errors.New("差异长度错误")
+		}
+		if _, err := io.ReadFull(diff, newData[newPos:newPos+c[0]]); err != nil {
+			return nil, fmt.Errorf("读取差异块失败: %v", err)
+		}
+		for i := 0; i < c[0]; i++ {
+			if oldPos+i >= 0 && oldPos+i < len(oldData) {
+				newData[newPos+i] += oldData[oldPos+i]
+			}
+		}
+		newPos += c[0]
+		oldPos += c[0]
+
+		if c[1] < 0 || newPos+c[1] > newSize {
+			return nil, errors.New("新增长度错误")
+		}
+		if _, err := io.ReadFull(extra, newData[newPos:newPos+c[1]]); err != nil {
+			return nil, fmt.Errorf("读取新增块失败: %v", err)
+		}
+		newPos += c[1]
+		oldPos += c[2]
+	}
+	return newData, nil
+}
+
+// offtin 解码bsdiff格式的整数
+func offtin(buf []byte) int {
+	y := int(binary.LittleEndian.Uint64(buf) &^ (1 << 63))
+	if buf[7]&0x80 != 0 {
+		y = -y
+	}
+	return y
+}
+
+func TestBsdiff(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 64<<10)
+	r.Read(random)
+
+	// 随机修改部分字节，并插入、删除一段
+	modified := append([]byte(nil), random...)
+	for i := 0; i < 100; i++ {
+		modified[r.Intn(len(modified))] ^= 0xff
+	}
+	modified = append(modified[:1000], append([]byte("inserted"), modified[1000:]...)...)
+	modified = append(modified[:30000], modified[31000:]...)
+
+	text := []byte("version=3.1.0\nservices=frpc,cgKeepalive\n")
+
+	tests := []struct {
+		name     string
+		old, new []byte
+	}{
+		{"都为空", nil, nil},
+		{"旧文件为空", nil, text},
+		{"新文件为空", text, nil},
+		{"相同", text, text},
+		{"修改文本", text, []byte("version=3.2.0\nservices=frpc,cgKeepalive,cgManager\n")},
+		{"重复内容", bytes.Repeat([]byte{0}, 4096), bytes.Repeat([]byte{0}, 5000)},
+		{"随机修改", random, modified},
+		{"完全不同", random[:4096], random[4096:8192]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch := Bsdiff(tt.old, tt.new)
+			got, err := bspatch(tt.old, patch)
+			if err != nil {
+				t.Fatalf("应用补丁失败: %v", err)
+			}
+			if !bytes.Equal(got, tt.new) {
+				t.Errorf("还原结果不一致: 长度 %d, 期望 %d", len(got), len(tt.new))
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package utils
+
+import (
+	"bytes"
+	"container/heap"
+)
+
+// 标准库只提供bzip2解压，这里实现一个简单的bzip2压缩器，用于生成bspatch可识别的补丁文件。
+// 压缩率不追求最优：每个块只使用一张哈夫曼表。
+
+const (
+	bz2MaxBlockSize = 900000 - 19 // 块大小上限(经过首轮游程编码后)
+	bz2GroupSize    = 50          // 每个选择器对应的符号数
+	bz2MaxCodeLen   = 17          // 哈夫曼编码最大长度
+	bz2NumTables    = 2           // 哈夫曼表数量，格式要求至少为2
+)
+
+var bz2CrcTable = func() (table [256]uint32) {
+	for i := range table {
+		c := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04c11db7
+			} else {
+				c <<= 1
+			}
+		}
+		table[i] = c
+	}
+	return
+}()
+
+// Bzip2Compress 将数据压缩为bzip2格式
+func Bzip2Compress(data []byte) []byte {
+	w := &bitWriter{}
+	w.buf.WriteString("BZh9")
+
+	var combinedCRC uint32
+	for len(data) > 0 {
+		block, consumed := bz2RLE1(data)
+		crc := bz2CRC(data[:consumed])
+		combinedCRC = (combinedCRC<<1 | combinedCRC>>31) ^ crc
+		bz2WriteBlock(w, block, crc)
+		data = data[consumed:]
+	}
+
+	// 流结束标识
+	w.writeBits(0x177245, 24)
+	w.writeBits(0x385090, 24)
+	w.writeBits(uint64(combinedCRC), 32)
+	w.flush()
+
+	return w.buf.Bytes()
+}
+
+func bz2CRC(data []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, b := range data {
+		crc = crc<<8 ^ bz2CrcTable[byte(crc>>24)^b]
+	}
+	return ^crc
+}
+
+// bz2RLE1 首轮游程编码，返回一个块的编码结果及消耗的原始数据长度
+func bz2RLE1(data []byte) ([]byte, int) {
+	out := make([]byte, 0, bz2MaxBlockSize)
+	i := 0
+	for i < len(data) {
+		run := 1
+		for i+run < len(data) && run < 255 && data[i+run] == data[i] {
+			run++
+		}
+
+		size := run
+		if run >= 4 {
+			size = 5
+		}
+		if len(out)+size > bz2MaxBlockSize {
+			break
+		}
+
+		if run >= 4 {
+			out = append(out, data[i], data[i], data[i], data[i], byte(run-4))
+		} else {
+			for j := 0; j < run; j++ {
+				out = append(out, data[i])
+			}
+		}
+		i += run
+	}
+
+	return out, i
+}
+
+func bz2WriteBlock(w *bitWriter, block []byte, crc uint32) {
+	bwt, origPtr := bz2BWT(block)
+
+	// 使用到的字节
+	var inUse [256]bool
+	for _, b := range block {
+		inUse[b] = true
+	}
+	var unseqToSeq [256]byte
+	nInUse := 0
+	for i := 0; i < 256; i++ {
+		if inUse[i] {
+			unseqToSeq[i] = byte(nInUse)
+			nInUse++
+		}
+	}
+
+	symbols := bz2MTF(bwt, unseqToSeq, nInUse)
+	alphaSize := nInUse + 2
+
+	// 统计符号频率并生成哈夫曼编码
+	freqs := make([]int, alphaSize)
+	for _, s := range symbols {
+		freqs[s]++
+	}
+	lengths := bz2CodeLengths(freqs, bz2MaxCodeLen)
+	codes := bz2AssignCodes(lengths)
+
+	// 块头
+	w.writeBits(0x314159, 24)
+	w.writeBits(0x265359, 24)
+	w.writeBits(uint64(crc), 32)
+	w.writeBits(0, 1)
+	w.writeBits(uint64(origPtr), 24)
+
+	// 使用到的字节位图
+	var inUse16 uint64
+	for i := 0; i < 16; i++ {
+		for j := 0; j < 16; j++ {
+			if inUse[i*16+j] {
+				inUse16 |= 1 << (15 - i)
+				break
+			}
+		}
+	}
+	w.writeBits(inUse16, 16)
+	for i := 0; i < 16; i++ {
+		if inUse16&(1<<(15-i)) == 0 {
+			continue
+		}
+		var bits uint64
+		for j := 0; j < 16; j++ {
+			if inUse[i*16+j] {
+				bits |= 1 << (15 - j)
+			}
+		}
+		w.writeBits(bits, 16)
+	}
+
+	// 选择器全部使用第一张表
+	nSelectors := (len(symbols) + bz2GroupSize - 1) / bz2GroupSize
+	w.writeBits(bz2NumTables, 3)
+	w.writeBits(uint64(nSelectors), 15)
+	for i := 0; i < nSelectors; i++ {
+		w.writeBits(0, 1)
+	}
+
+	// 编码长度表
+	for t := 0; t < bz2NumTables; t++ {
+		curr := lengths[0]
+		w.writeBits(uint64(curr), 5)
+		for _, l := range lengths {
+			for curr < l {
+				w.writeBits(2, 2)
+				curr++
+			}
+			for curr > l {
+				w.writeBits(3, 2)
+				curr--
+			}
+			w.writeBits(0, 1)
+		}
+	}
+
+	for _, s := range symbols {
+		w.writeBits(uint64(codes[s]), uint(lengths[s]))
+	}
+}
+
+// bz2BWT 对循环移位排序(倍增法)，返回最后一列及原始数据所在行
+func bz2BWT(block []byte) ([]byte, int) {
+	n := len(block)
+	p := make([]int, n)
+	c := make([]int, n)
+	pn := make([]int, n)
+	cn := make([]int, n)
+	cnt := make([]int, max(256, n))
+
+	for _, b := range block {
+		cnt[b]++
+	}
+	for i := 1; i < 256; i++ {
+		cnt[i] += cnt[i-1]
+	}
+	for i := n - 1; i >= 0; i-- {
+		cnt[block[i]]--
+		p[cnt[block[i]]] = i
+	}
+	classes := 1
+	for i := 1; i < n; i++ {
+		if block[p[i]] != block[p[i-1]] {
+			classes++
+		}
+		c[p[i]] = classes - 1
+	}
+
+	for h := 1; h < n && classes < n; h <<= 1 {
+		for i := 0; i < n; i++ {
+			pn[i] = (p[i] - h + n) % n
+		}
+		for i := 0; i < classes; i++ {
+			cnt[i] = 0
+		}
+		for i := 0; i < n; i++ {
+			cnt[c[pn[i]]]++
+		}
+		for i := 1; i < classes; i++ {
+			cnt[i] += cnt[i-1]
+		}
+		for i := n - 1; i >= 0; i-- {
+			cnt[c[pn[i]]]--
+			p[cnt[c[pn[i]]]] = pn[i]
+		}
+		cn[p[0]] = 0
+		classes = 1
+		for i := 1; i < n; i++ {
+			if c[p[i]] != c[p[i-1]] || c[(p[i]+h)%n] != c[(p[i-1]+h)%n] {
+				classes++
+			}
+			cn[p[i]] = classes - 1
+		}
+		c, cn = cn, c
+	}
+
+	out := make([]byte, n)
+	origPtr := 0
+	for i, pos := range p {
+		if pos == 0 {
+			origPtr = i
+		}
+		out[i] = block[(pos+n-1)%n]
+	}
+
+	return out, origPtr
+}
+
+// bz2MTF 前移编码及零游程编码，返回包含块结束符的符号序列
+func bz2MTF(bwt []byte, unseqToSeq [256]byte, nInUse int) []uint16 {
+	const runA, runB = 0, 1
+
+	order := make([]byte, nInUse)
+	for i := range order {
+		order[i] = byte(i)
+	}
+
+	symbols := make([]uint16, 0, len(bwt)+1)
+	zeros := 0
+	flushZeros := func() {
+		for zeros > 0 {
+			zeros--
+			symbols = append(symbols, uint16(runA+zeros&1))
+			zeros >>= 1
+		}
+	}
+
+	for _, b := range bwt {
+		seq := unseqToSeq[b]
+		j := bytes.IndexByte(order, seq)
+		if j == 0 {
+			zeros++
+			continue
+		}
+
+		flushZeros()
+		copy(order[1:j+1], order[:j])
+		order[0] = seq
+		symbols = append(symbols, uint16(j+1))
+	}
+	flushZeros()
+
+	return append(symbols, uint16(nInUse+1))
+}
+
+// bz2CodeLengths 生成长度不超过maxLen的哈夫曼编码长度
+func bz2CodeLengths(freqs []int, maxLen int) []int {
+	weights := make([]int, len(freqs))
+	for i, f := range freqs {
+		weights[i] = max(f, 1)
+	}
+
+	for {
+		lengths := huffmanLengths(weights)
+		tooLong := false
+		for _, l := range lengths {
+			if l > maxLen {
+				tooLong = true
+				break
+			}
+		}
+		if !tooLong {
+			return lengths
+		}
+
+		// 编码过长时压缩权重后重新生成
+		for i := range weights {
+			weights[i] = 1 + weights[i]/2
+		}
+	}
+}
+
+type huffmanNode struct {
+	weight int
+	leaf   int // 叶子节点对应的符号，非叶子节点为-1
+	left   *huffmanNode
+	right  *huffmanNode
+}
+
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int           { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool { return h[i].weight < h[j].weight }
+func (h huffmanHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x any)        { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+func huffmanLengths(weights []int) []int {
+	h := &huffmanHeap{}
+	for i, w := range weights {
+		*h = append(*h, &huffmanNode{weight: w, leaf: i})
+	}
+	heap.Init(h)
+	for h.Len() > 1 {
+		a := heap.Pop(h).(*huffmanNode)
+		b := heap.Pop(h).(*huffmanNode)
+		heap.Push(h, &huffmanNode{weight: a.weight + b.weight, leaf: -1, left: a, right: b})
+	}
+
+	lengths := make([]int, len(weights))
+	var walk func(node *huffmanNode, depth int)
+	walk = func(node *huffmanNode, depth int) {
+		if node.leaf >= 0 {
+			lengths[node.leaf] = max(depth, 1)
+			return
+		}
+		walk(node.left, depth+1)
+		walk(node.right, depth+1)
+	}
+	walk(heap.Pop(h).(*huffmanNode), 0)
+
+	return lengths
+}
+
+// bz2AssignCodes 按编码长度及符号顺序分配规范哈夫曼编码
+func bz2AssignCodes(lengths []int) []uint32 {
+	minLen, maxLen := 32, 0
+	for _, l := range lengths {
+		minLen = min(minLen, l)
+		maxLen = max(maxLen, l)
+	}
+
+	codes := make([]uint32, len(lengths))
+	var code uint32
+	for l := minLen; l <= maxLen; l++ {
+		for i, length := range lengths {
+			if length == l {
+				codes[i] = code
+				code++
+			}
+		}
+		code <<= 1
+	}
+
+	return codes
+}
+
+// bitWriter 按高位优先写入比特流
+type bitWriter struct {
+	buf   bytes.Buffer
+	bits  uint64
+	nbits uint
+}
+
+func (w *bitWriter) writeBits(value uint64, n uint) {
+	w.bits = w.bits<<n | value&(1<<n-1)
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.nbits -= 8
+		w.buf.WriteByte(byte(w.bits >> w.nbits))
+	}
+}
+
+func (w *bitWriter) flush() {
+	if w.nbits > 0 {
+		w.buf.WriteByte(byte(w.bits << (8 - w.nbits)))
+		w.nbits = 0
+	}
+}
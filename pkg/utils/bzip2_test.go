@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestBzip2Compress(t *testing.T) {
+	random := make([]byte, 1<<20) // 超过单个块大小
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"空", nil},
+		{"单字节", []byte{0x42}},
+		{"文本", []byte("cgCollector cgUpdater frpc cgKeepalive\n")},
+		{"4字节重复", bytes.Repeat([]byte{'a'}, 4)},
+		{"5字节重复", bytes.Repeat([]byte{'a'}, 5)},
+		{"超过255字节重复", bytes.Repeat([]byte{'a'}, 1000)},
+		{"全部字节值", func() []byte {
+			data := make([]byte, 256)
+			for i := range data {
+				data[i] = byte(i)
+			}
+			return data
+		}()},
+		{"多块重复", bytes.Repeat([]byte("0123456789"), 200000)},
+		{"随机多块", random},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := Bzip2Compress(tt.data)
+			got, err := io.ReadAll(bzip2.NewReader(bytes.NewReader(compressed)))
+			if err != nil {
+				t.Fatalf("解压失败: %v", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("解压结果不一致: 长度 %d, 期望 %d", len(got), len(tt.data))
+			}
+		})
+	}
+}
@@ -157,13 +157,32 @@ func CreateTarGz(output string, directories []string) error {
 	}
 	defer file.Close()
 
+	return WriteTarGz(file, directories)
+}
+
+// WriteTarGz 将指定目录列表打包成 tar.gz 格式写入 w
+func WriteTarGz(w io.Writer, directories []string) error {
+	return WriteTarGzWithOverrides(w, directories, nil)
+}
+
+// WriteTarGzWithOverrides 将指定目录列表打包成 tar.gz 格式写入 w
+// overrides 中的文件(以 / 分隔的路径，如 v3_install/config/cgManager.yaml)使用给定内容替换本地文件内容
+func WriteTarGzWithOverrides(w io.Writer, directories []string, overrides map[string][]byte) (err error) {
 	// 创建 gzip 写入器
-	gw := gzip.NewWriter(file)
-	defer gw.Close()
+	gw := gzip.NewWriter(w)
+	defer func() {
+		if closeErr := gw.Close(); err == nil {
+			err = closeErr
+		}
+	}()
 
 	// 创建 tar 写入器
 	tw := tar.NewWriter(gw)
-	defer tw.Close()
+	defer func() {
+		if closeErr := tw.Close(); err == nil {
+			err = closeErr
+		}
+	}()
 
 	for _, dir := range directories {
 		// 遍历目录并添加文件到 tar
@@ -179,6 +198,16 @@ func CreateTarGz(output string, directories []string) error {
 			}
 			header.Name = filepath.ToSlash(path)
 
+			// 使用替换的内容
+			if content, ok := overrides[header.Name]; ok && !info.IsDir() {
+				header.Size = int64(len(content))
+				if err := tw.WriteHeader(header); err != nil {
+					return err
+				}
+				_, err := tw.Write(content)
+				return err
+			}
+
 			// 写入头到 tar
 			if err := tw.WriteHeader(header); err != nil {
 				return err
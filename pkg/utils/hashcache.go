@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// HashCache 本地文件hash缓存，文件大小和修改时间未变化时直接使用缓存的hash值
+type HashCache struct {
+	path    string
+	entries map[string]hashCacheEntry
+	lock    sync.Mutex
+}
+
+type hashCacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+	Hash    string `json:"hash"`
+}
+
+// NewHashCache 创建hash缓存，缓存文件不存在或无法解析时从空缓存开始
+func NewHashCache(path string) *HashCache {
+	c := &HashCache{
+		path:    path,
+		entries: make(map[string]hashCacheEntry),
+	}
+
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &c.entries)
+	}
+
+	return c
+}
+
+// FileSHA256 获取文件的sha256，优先使用缓存
+func (c *HashCache) FileSHA256(filePath string) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	c.lock.Lock()
+	entry, ok := c.entries[key]
+	c.lock.Unlock()
+	if ok && entry.Size == info.Size() && entry.ModTime == info.ModTime().UnixNano() {
+		return entry.Hash, nil
+	}
+
+	hash, err := FileSHA256(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	c.lock.Lock()
+	c.entries[key] = hashCacheEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Hash: hash}
+	c.lock.Unlock()
+
+	return hash, nil
+}
+
+// Save 删除已不存在的文件后保存缓存到文件
+func (c *HashCache) Save() error {
+	c.lock.Lock()
+	for key := range c.entries {
+		if _, err := os.Stat(key); os.IsNotExist(err) {
+			delete(c.entries, key)
+		}
+	}
+	data, err := json.Marshal(c.entries)
+	c.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return WriteFile(c.path, data)
+}
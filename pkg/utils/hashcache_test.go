@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashCacheSavePrunesMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "kept.bin")
+	removed := filepath.Join(dir, "removed.bin")
+	for _, file := range []string{kept, removed} {
+		if err := os.WriteFile(file, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cachePath := filepath.Join(dir, "cache", "file_hash.json")
+	c := NewHashCache(cachePath)
+	for _, file := range []string{kept, removed} {
+		if _, err := c.FileSHA256(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(removed); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewHashCache(cachePath)
+	if len(loaded.entries) != 1 {
+		t.Fatalf("缓存条目数 = %d, 期望 1", len(loaded.entries))
+	}
+	if _, ok := loaded.entries[kept]; !ok {
+		t.Errorf("缓存中缺少 %s", kept)
+	}
+}
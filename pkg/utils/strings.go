@@ -0,0 +1,11 @@
+package utils
+
+// ContainsString 列表中是否包含字符串s
+func ContainsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -42,12 +42,15 @@ frpc_dir="frpc"
 if [ ! -d $tmp_path ]; then
     error_exit "程序请放在$tmp_path目录下"
 else
-    if [ ! -e "$tmp_path/$tar_file" ]; then
-        error_exit "请先将$tar_file文件放在$tmp_path目录下"
+    if [ ! -e "$tmp_path/$tar_file" ] && [ ! -d "$tmp_v2_path" ]; then
+        error_exit "请先将$tar_file文件或解压后的文件放在$tmp_path目录下"
     else
         cd $tmp_path || error_exit "无法进入目录 $tmp_path"
-        
-        tar -zxvf $tar_file || error_exit "解压 $tar_file 失败"
+
+        # 固件可能已由工具直接解压到临时目录
+        if [ -e "$tmp_path/$tar_file" ]; then
+            tar -zxvf $tar_file || error_exit "解压 $tar_file 失败"
+        fi
 
         # 删除旧的文件
         rm -rf $root_path/$pro_dir/* || error_exit "删除旧的 $root_path/$pro_dir 目录文件失败"
@@ -67,8 +70,11 @@ else
         chmod -R 755 $root_path/$pro_dir/bin
         chmod -R 755 $root_path/$frpc_dir
 
-        # 修改frpc.toml的name
-        sed -i "s/name = \".*\"/name = \"${gwSN}\"/" $root_path/$frpc_dir/frpc.toml || error_exit "修改 frpc.toml 文件失败"
+        # frpc.toml一般已由工具按设备生成，未生成时按传入的SN修改name
+        if grep -q 'name = "NAME_NO_SET"' $root_path/$frpc_dir/frpc.toml; then
+            log "frpc.toml 未按设备生成，使用 ${gwSN}"
+            sed -i "s/name = \".*\"/name = \"${gwSN}\"/" $root_path/$frpc_dir/frpc.toml || error_exit "修改 frpc.toml 文件失败"
+        fi
 
         # 如果系统服务已经存在，先停止并禁用它们
         systemctl stop frpc || true
@@ -42,12 +42,15 @@ frpc_dir="frpc"
 if [ ! -d $tmp_path ]; then
     error_exit "程序请放在$tmp_path目录下"
 else
-    if [ ! -e "$tmp_path/$tar_file" ]; then
-        error_exit "请先将$tar_file文件放在$tmp_path目录下"
+    if [ ! -e "$tmp_path/$tar_file" ] && [ ! -d "$tmp_v3_path" ]; then
+        error_exit "请先将$tar_file文件或解压后的文件放在$tmp_path目录下"
     else
         cd $tmp_path || error_exit "无法进入目录 $tmp_path"
-        
-        tar -zxvf $tar_file || error_exit "解压 $tar_file 失败"
+
+        # 固件可能已由工具直接解压到临时目录
+        if [ -e "$tmp_path/$tar_file" ]; then
+            tar -zxvf $tar_file || error_exit "解压 $tar_file 失败"
+        fi
 
         # 删除旧的文件
         rm -rf $root_path/$pro_dir/* || error_exit "删除旧的 $root_path/$pro_dir 目录文件失败"
@@ -63,18 +66,29 @@ else
         mv $tmp_v3_path/bin/* $root_path/$pro_dir/bin || error_exit "移动 bin 文件失败"
         mv $tmp_v3_path/config/* $root_path/$pro_dir/data/config || error_exit "移动 config 文件失败"
 
-        # 修改cgManager.yaml的网关型号
-        sed -i "s/  device_type: \".*\"/  device_type: \"EM500\"/" $root_path/$pro_dir/data/config/cgManager.yaml || error_exit "修改 cgManager.yaml 文件失败"
-        sed -i "s/  device_sn: \".*\"/  device_sn: \"${gwSN}\"/" $root_path/$pro_dir/data/config/cgManager.yaml || error_exit "修改 cgManager.yaml 文件失败"
+        # cgManager.yaml的网关型号和SN一般已由工具写入，未写入时按传入的SN修改
+        if grep -q '  device_sn: ""' $root_path/$pro_dir/data/config/cgManager.yaml; then
+            log "cgManager.yaml 未写入SN，使用 ${gwSN}"
+            sed -i "s/  device_type: \".*\"/  device_type: \"EM500\"/" $root_path/$pro_dir/data/config/cgManager.yaml || error_exit "修改 cgManager.yaml 文件失败"
+            sed -i "s/  device_sn: \".*\"/  device_sn: \"${gwSN}\"/" $root_path/$pro_dir/data/config/cgManager.yaml || error_exit "修改 cgManager.yaml 文件失败"
+        fi
 
         chmod -R 755 $root_path/$pro_dir/bin
         chmod -R 755 $root_path/$frpc_dir
 
-        # 修改frpc.toml的name
-        sed -i "s/name = \".*\"/name = \"${gwSN}\"/" $root_path/$frpc_dir/frpc.toml || error_exit "修改 frpc.toml 文件失败"
+        # frpc.toml一般已由工具按设备生成，未生成时按传入的SN修改name
+        if grep -q 'name = "NAME_NO_SET"' $root_path/$frpc_dir/frpc.toml; then
+            log "frpc.toml 未按设备生成，使用 ${gwSN}"
+            sed -i "s/name = \".*\"/name = \"${gwSN}\"/" $root_path/$frpc_dir/frpc.toml || error_exit "修改 frpc.toml 文件失败"
+        fi
 
-        # 修改时区
-        sudo timedatectl set-timezone Asia/Shanghai || error_exit "设置时区失败"
+        # 修改时区，已在工具中设置过时区时保留(包括选择UTC)
+        current_tz=$(timedatectl show -p Timezone --value 2>/dev/null || echo "UTC")
+        if [ -f /etc/eminit_timezone ]; then
+            log "保留工具设置的时区 ${current_tz}"
+        elif [ -z "$current_tz" ] || [ "$current_tz" = "UTC" ] || [ "$current_tz" = "Etc/UTC" ]; then
+            sudo timedatectl set-timezone Asia/Shanghai || error_exit "设置时区失败"
+        fi
 
         # 如果系统服务已经存在，先停止并禁用它们
         systemctl stop frpc || true